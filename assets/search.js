@@ -0,0 +1,196 @@
+// Krems site search. Loads the index written by the build (search/index.json
+// and, when the index is sharded, one file per section) on the first query
+// and searches it in the browser. No dependencies.
+(function () {
+    "use strict";
+
+    var root = document.getElementById("search");
+    if (!root) {
+        return;
+    }
+    var input = document.getElementById("search-input");
+    var section = document.getElementById("search-section");
+    var status = document.getElementById("search-status");
+    var results = document.getElementById("search-results");
+
+    var maxResults = 50;
+    var snippetLength = 160;
+    var index = null; // {pages: [...]} or {sections: [...]}
+    var shards = {}; // url => promise of pages
+
+    function fetchJSON(url) {
+        return fetch(url).then(function (res) {
+            if (!res.ok) {
+                throw new Error(url + ": " + res.status);
+            }
+            return res.json();
+        });
+    }
+
+    function loadIndex() {
+        if (!index) {
+            index = fetchJSON(root.getAttribute("data-index")).then(function (data) {
+                if (data.sections && section) {
+                    data.sections.forEach(function (s) {
+                        var option = document.createElement("option");
+                        option.value = s.url;
+                        option.textContent = s.title;
+                        section.appendChild(option);
+                    });
+                    section.hidden = false;
+                }
+                return data;
+            });
+        }
+        return index;
+    }
+
+    function loadShard(url) {
+        if (!shards[url]) {
+            shards[url] = fetchJSON(url).then(function (data) {
+                return data.pages || [];
+            });
+        }
+        return shards[url];
+    }
+
+    // pagesToSearch resolves to the pages of the selected section, or of the
+    // whole site.
+    function pagesToSearch() {
+        return loadIndex().then(function (data) {
+            if (!data.sections) {
+                return data.pages || [];
+            }
+            var urls = data.sections.map(function (s) { return s.url; });
+            if (section && section.value) {
+                urls = [section.value];
+            }
+            return Promise.all(urls.map(loadShard)).then(function (lists) {
+                return [].concat.apply([], lists);
+            });
+        });
+    }
+
+    function terms(query) {
+        return query.toLowerCase().split(/\s+/).filter(function (t) { return t !== ""; });
+    }
+
+    // score ranks a page by where the terms occur; 0 means some term is
+    // missing.
+    function score(page, words) {
+        var title = page.title.toLowerCase();
+        var meta = ((page.tags || []).join(" ") + " " + (page.author || "")).toLowerCase();
+        var text = page.text.toLowerCase();
+        var total = 0;
+        for (var i = 0; i < words.length; i++) {
+            var w = words[i];
+            var s = 0;
+            if (title.indexOf(w) >= 0) {
+                s += 10;
+            }
+            if (meta.indexOf(w) >= 0) {
+                s += 5;
+            }
+            if (text.indexOf(w) >= 0) {
+                s += 1;
+            }
+            if (s === 0) {
+                return 0;
+            }
+            total += s;
+        }
+        return total;
+    }
+
+    function escapeHTML(s) {
+        return s.replace(/[&<>"']/g, function (c) {
+            return {"&": "&amp;", "<": "&lt;", ">": "&gt;", "\"": "&quot;", "'": "&#39;"}[c];
+        });
+    }
+
+    function escapeRegExp(s) {
+        return s.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+    }
+
+    // snippet is the text around the first term, with the terms marked.
+    // Matches are found in the plain text and each piece is escaped on its
+    // own, so a term never lands inside an entity such as &amp;.
+    function snippet(text, words) {
+        var lower = text.toLowerCase();
+        var at = -1;
+        for (var i = 0; i < words.length && at < 0; i++) {
+            at = lower.indexOf(words[i]);
+        }
+        var start = Math.max(0, at - snippetLength / 4);
+        var part = text.substr(start, snippetLength);
+        var re = new RegExp(words.map(escapeRegExp).join("|"), "gi");
+        var html = "";
+        var last = 0;
+        var m;
+        while ((m = re.exec(part)) !== null) {
+            html += escapeHTML(part.slice(last, m.index)) + "<mark>" + escapeHTML(m[0]) + "</mark>";
+            last = m.index + m[0].length;
+        }
+        html += escapeHTML(part.slice(last));
+        return (start > 0 ? "&hellip;" : "") + html + (start + snippetLength < text.length ? "&hellip;" : "");
+    }
+
+    function render(query, pages) {
+        var words = terms(query);
+        results.innerHTML = "";
+        if (words.length === 0) {
+            status.textContent = "";
+            return;
+        }
+        var found = pages.map(function (p) {
+            return {page: p, score: score(p, words)};
+        }).filter(function (r) {
+            return r.score > 0;
+        }).sort(function (a, b) {
+            return b.score - a.score || (b.page.date || "").localeCompare(a.page.date || "");
+        });
+
+        status.textContent = found.length === 1 ? "1 page found" : found.length + " pages found";
+        found.slice(0, maxResults).forEach(function (r) {
+            var li = document.createElement("li");
+            li.className = "mb-4";
+            li.innerHTML = "<a class=\"text-decoration-none\" href=\"" + escapeHTML(r.page.url) + "\">" +
+                escapeHTML(r.page.title || r.page.url) + "</a>" +
+                (r.page.date ? " <span class=\"text-muted small\">" + escapeHTML(r.page.date) + "</span>" : "") +
+                "<div class=\"small\">" + snippet(r.page.text, words) + "</div>";
+            results.appendChild(li);
+        });
+    }
+
+    var pending = 0;
+    function search() {
+        var query = input.value;
+        var params = new URLSearchParams(window.location.search);
+        if (query) {
+            params.set("q", query);
+        } else {
+            params.delete("q");
+        }
+        var qs = params.toString();
+        window.history.replaceState(null, "", window.location.pathname + (qs ? "?" + qs : ""));
+
+        var id = ++pending;
+        pagesToSearch().then(function (pages) {
+            if (id === pending) {
+                render(query, pages);
+            }
+        }).catch(function (err) {
+            status.textContent = "Search is unavailable: " + err.message;
+        });
+    }
+
+    input.addEventListener("input", search);
+    if (section) {
+        section.addEventListener("change", search);
+    }
+    var initial = new URLSearchParams(window.location.search).get("q");
+    if (initial) {
+        input.value = initial;
+        search();
+    }
+})();
@@ -4,58 +4,91 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// buildOptions are the command line settings that affect a build.
+type buildOptions struct {
+	DevMode bool // build for local development (krems --run)
+	Jobs    int  // number of pages rendered in parallel
+	Drafts  bool // include pages with draft: true
+	Future  bool // include pages whose publishDate is still ahead
+	Expired bool // include pages whose expiryDate has passed
+	Strict  bool // fail on broken links, missing images and menu paths
+}
+
 // handleBuild => krems --build
-// isDevMode indicates if the build is for local development (krems --run)
 // outputDir specifies where to build the site.
-func handleBuild(isDevMode bool, outputDir string) {
-	// remove outputDir if exists
-	_ = os.RemoveAll(outputDir)
+func handleBuild(outputDir string, opts buildOptions) {
+	if err := buildSite(outputDir, opts); err != nil {
+		fmt.Printf("Build failed: %v\n", err)
+		os.Exit(1)
+	}
+}
 
+// buildSite does the actual work behind handleBuild. It returns an error
+// instead of exiting so the dev server can rebuild without stopping.
+func buildSite(outputDir string, opts buildOptions) error {
 	// read config.yaml
 	cfg, err := readConfig("config.yaml")
 	if err != nil {
-		fmt.Printf("Error reading config.yaml: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error reading config.yaml: %w", err)
 	}
 
 	// Determine the effective base path
 	// If isDevMode is true and DevPath is set, use DevPath. Otherwise, use BasePath.
-	if isDevMode && cfg.Website.DevPath != "" {
+	if opts.DevMode && cfg.Website.DevPath != "" {
 		cfg.Website.BasePath = cfg.Website.DevPath
 	}
 	// If not in dev mode, or DevPath is not set, cfg.Website.BasePath remains as read from config.yaml
 	// or its default if not specified.
 
+	// Reuse the previous build when the manifest says config and templates are unchanged;
+	// otherwise remove outputDir and render everything.
+	layoutSources, err := readLayoutSources(userLayoutsDir)
+	if err != nil {
+		return fmt.Errorf("error reading layouts: %w", err)
+	}
+	hash := siteHash(cfg, layoutSources)
+	previous := loadManifest(outputDir)
+	if previous == nil || previous.Version != manifestVersion || previous.SiteHash != hash {
+		previous = nil
+		_ = os.RemoveAll(outputDir)
+	} else {
+		fmt.Println("Incremental build: re-rendering changed pages only.")
+		// A failed build must not leave a manifest claiming its outputs are current.
+		_ = os.Remove(filepath.Join(outputDir, manifestFileName))
+		// Assets are copied again below; start them fresh so deleted files don't linger.
+		for _, dir := range []string{"css", "js", "images"} {
+			_ = os.RemoveAll(filepath.Join(outputDir, dir))
+		}
+	}
+	next := newBuildManifest(hash)
+
 	// Handle CSS
 	if cfg.Website.AlternativeCSSDir != "" {
 		fmt.Printf("Using alternative CSS from: %s\n", cfg.Website.AlternativeCSSDir)
 		cssOutputDir := filepath.Join(outputDir, "css")
 		if err := os.MkdirAll(cssOutputDir, 0755); err != nil {
-			fmt.Printf("Error creating css output directory %s: %v\n", cssOutputDir, err)
-			os.Exit(1)
+			return fmt.Errorf("error creating css output directory %s: %w", cssOutputDir, err)
 		}
 		files, err := os.ReadDir(cfg.Website.AlternativeCSSDir)
 		if err != nil {
-			fmt.Printf("Error reading alternative CSS directory %s: %v\n", cfg.Website.AlternativeCSSDir, err)
-			os.Exit(1)
+			return fmt.Errorf("error reading alternative CSS directory %s: %w", cfg.Website.AlternativeCSSDir, err)
 		}
 		for _, file := range files {
 			if !file.IsDir() && filepath.Ext(file.Name()) == ".css" {
 				srcPath := filepath.Join(cfg.Website.AlternativeCSSDir, file.Name())
 				destPath := filepath.Join(cssOutputDir, file.Name())
 				if err := copyFile(srcPath, destPath); err != nil {
-					fmt.Printf("Error copying alternative CSS file %s to %s: %v\n", srcPath, destPath, err)
-					os.Exit(1)
+					return fmt.Errorf("error copying alternative CSS file %s to %s: %w", srcPath, destPath, err)
 				}
 				fmt.Printf("Copied alternative CSS: %s\n", destPath)
 			}
 		}
 	} else {
 		if err := createInternalCSS(outputDir); err != nil {
-			fmt.Printf("Error creating internal CSS: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error creating internal CSS: %w", err)
 		}
 	}
 
@@ -64,29 +97,37 @@ func handleBuild(isDevMode bool, outputDir string) {
 		fmt.Printf("Using alternative JS from: %s\n", cfg.Website.AlternativeJSDir)
 		jsOutputDir := filepath.Join(outputDir, "js")
 		if err := os.MkdirAll(jsOutputDir, 0755); err != nil {
-			fmt.Printf("Error creating js output directory %s: %v\n", jsOutputDir, err)
-			os.Exit(1)
+			return fmt.Errorf("error creating js output directory %s: %w", jsOutputDir, err)
 		}
 		files, err := os.ReadDir(cfg.Website.AlternativeJSDir)
 		if err != nil {
-			fmt.Printf("Error reading alternative JS directory %s: %v\n", cfg.Website.AlternativeJSDir, err)
-			os.Exit(1)
+			return fmt.Errorf("error reading alternative JS directory %s: %w", cfg.Website.AlternativeJSDir, err)
 		}
 		for _, file := range files {
 			if !file.IsDir() && filepath.Ext(file.Name()) == ".js" {
 				srcPath := filepath.Join(cfg.Website.AlternativeJSDir, file.Name())
 				destPath := filepath.Join(jsOutputDir, file.Name())
 				if err := copyFile(srcPath, destPath); err != nil {
-					fmt.Printf("Error copying alternative JS file %s to %s: %v\n", srcPath, destPath, err)
-					os.Exit(1)
+					return fmt.Errorf("error copying alternative JS file %s to %s: %w", srcPath, destPath, err)
 				}
 				fmt.Printf("Copied alternative JS: %s\n", destPath)
 			}
 		}
 	} else {
 		if err := createInternalJS(outputDir); err != nil {
-			fmt.Printf("Error creating internal JS: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error creating internal JS: %w", err)
+		}
+	}
+	if cfg.Highlight.IsEnabled() {
+		if err := writeSyntaxCSS(cfg.Highlight, outputDir); err != nil {
+			return fmt.Errorf("error creating syntax highlighting CSS: %w", err)
+		}
+	}
+
+	// The search page needs its script even with alternative JS.
+	if cfg.Search.Enabled {
+		if err := createSearchJS(outputDir); err != nil {
+			return fmt.Errorf("error creating search JS: %w", err)
 		}
 	}
 
@@ -95,60 +136,77 @@ func handleBuild(isDevMode bool, outputDir string) {
 		fmt.Printf("Using alternative favicon from: %s\n", cfg.Website.AlternativeFavicon)
 		imagesOutputDir := filepath.Join(outputDir, "images")
 		if err := os.MkdirAll(imagesOutputDir, 0755); err != nil {
-			fmt.Printf("Error creating images output directory %s: %v\n", imagesOutputDir, err)
-			os.Exit(1)
+			return fmt.Errorf("error creating images output directory %s: %w", imagesOutputDir, err)
 		}
 		// Assuming the alternative favicon should be named favicon.ico in the output
 		destPath := filepath.Join(imagesOutputDir, "favicon.ico")
 		if err := copyFile(cfg.Website.AlternativeFavicon, destPath); err != nil {
-			fmt.Printf("Error copying alternative favicon from %s to %s: %v\n", cfg.Website.AlternativeFavicon, destPath, err)
-			os.Exit(1)
+			return fmt.Errorf("error copying alternative favicon from %s to %s: %w", cfg.Website.AlternativeFavicon, destPath, err)
 		}
 		fmt.Printf("Copied alternative favicon: %s\n", destPath)
 	} else {
 		if err := createInternalFavicon(outputDir); err != nil {
-			fmt.Printf("Error creating internal favicon: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error creating internal favicon: %w", err)
 		}
 	}
 
 	// copy user-provided static assets (js, images) from root => outputDir/
 	// This will overwrite embedded files if user provides their own versions.
 	if err := copyStaticAssets(outputDir); err != nil {
-		fmt.Printf("Error copying static assets: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error copying static assets: %w", err)
 	}
 
 	// parse all .md => PageData
-	pages, err := parseMarkdownFiles(".")
+	loc, err := siteLocation(cfg)
 	if err != nil {
-		fmt.Printf("Error parsing markdown: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+	pages, err := parseMarkdownFiles(".", loc)
+	if err != nil {
+		return fmt.Errorf("error parsing markdown: %w", err)
 	}
+	// Unpublished pages must go before tag, author and list pages see them.
+	pages = filterUnpublished(pages, opts, time.Now())
 
 	// create BuildCache
 	cache := &BuildCache{
 		Pages:                 pages,
 		Config:                cfg,
 		CurrentBuildOutputDir: outputDir, // Set the current build output directory
+		Manifest:              previous,
+		NextManifest:          next,
 	}
 	assignGlobalCache(cache)
+	if err := assignOutputDirs(cache, outputDir); err != nil {
+		return err
+	}
 
-	// process pages => rewrite links => HTML => final
-	if err := processPages(cache, outputDir); err != nil {
-		fmt.Printf("Error processing pages: %v\n", err)
-		os.Exit(1)
+	// Register author and tag list pages before rendering starts
+	addAuthorPages(cache, outputDir)
+	addTagPages(cache, outputDir)
+	addSearchPage(cache, outputDir)
+	paginateListPages(cache)
+	redirects, err := siteRedirects(cache)
+	if err != nil {
+		return err
+	}
+	if err := checkOutputCollisions(cache, outputDir, redirects); err != nil {
+		return err
 	}
 
-	// Generate author and tag list pages
-	if err := generateAuthorPages(cache, outputDir); err != nil {
-		fmt.Printf("Error generating author pages: %v\n", err)
-		os.Exit(1)
+	if err := prepareSiteTemplate(cache, outputDir, layoutSources); err != nil {
+		return fmt.Errorf("error parsing layouts: %w", err)
 	}
 
-	if err := generateTagPages(cache, outputDir); err != nil {
-		fmt.Printf("Error generating tag pages: %v\n", err)
-		os.Exit(1)
+	// process pages => rewrite links => HTML => final
+	if err := processPages(cache, outputDir, opts.Jobs); err != nil {
+		return fmt.Errorf("error processing pages: %w", err)
+	}
+	if err := reportProblems(cache, "config.yaml", opts.Strict); err != nil {
+		return err
+	}
+	if err := copyAttachments(cache, outputDir); err != nil {
+		return fmt.Errorf("error copying wikilink attachments: %w", err)
 	}
 
 	domain := extractDomain(cache.Config.Website.URL)
@@ -163,17 +221,37 @@ func handleBuild(isDevMode bool, outputDir string) {
 		}
 	}
 
-	// generate rss.xml
-	if err := generateRSS(cache, outputDir); err != nil {
-		fmt.Printf("Error generating RSS: %v\n", err)
-		os.Exit(1)
+	// generate rss.xml, atom.xml and feed.json
+	if err := generateFeeds(cache, outputDir); err != nil {
+		return fmt.Errorf("error generating feeds: %w", err)
+	}
+
+	if err := generateSearchIndex(cache, outputDir); err != nil {
+		return fmt.Errorf("error generating search index: %w", err)
+	}
+
+	if err := generateSitemap(cache, outputDir); err != nil {
+		return fmt.Errorf("error generating sitemap: %w", err)
+	}
+	if err := generateRobots(cache, outputDir); err != nil {
+		return fmt.Errorf("error generating robots.txt: %w", err)
+	}
+
+	if err := generateRedirects(cache, outputDir, redirects); err != nil {
+		return fmt.Errorf("error generating redirects: %w", err)
 	}
 
 	// create 404.html
 	if err := create404Page(cache, outputDir); err != nil {
-		fmt.Printf("Error creating 404.html: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error creating 404.html: %w", err)
+	}
+
+	removeStaleOutputs(previous, next, outputDir)
+	if err := next.save(outputDir); err != nil {
+		return fmt.Errorf("error writing build manifest: %w", err)
 	}
+	fmt.Printf("Rendered %d page(s), %d unchanged.\n", next.rendered, next.skipped)
 
 	fmt.Printf("Build complete! The '%s' directory is ready.\n", outputDir)
+	return nil
 }
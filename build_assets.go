@@ -29,6 +29,9 @@ var embeddedSourceSansFont embed.FS
 //go:embed assets/bootstrap.js
 var embeddedBootstrapJS embed.FS
 
+//go:embed assets/search.js
+var embeddedSearchJS embed.FS
+
 //go:embed assets/favicon.ico
 var embeddedFaviconICO embed.FS
 
@@ -122,6 +125,25 @@ func createInternalJS(outputBaseDir string) error {
 	return nil
 }
 
+// createSearchJS writes the search page script => outputBaseDir/js/search.js
+func createSearchJS(outputBaseDir string) error {
+	jsDir := filepath.Join(outputBaseDir, "js")
+	if err := os.MkdirAll(jsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output js directory %s: %w", jsDir, err)
+	}
+
+	jsData, err := fs.ReadFile(embeddedSearchJS, "assets/search.js")
+	if err != nil {
+		return fmt.Errorf("failed to read embedded search.js: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(jsDir, "search.js"), jsData, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write search.js: %w", err)
+	}
+	fmt.Printf("Created internal: %s\n", filepath.Join(jsDir, "search.js"))
+	return nil
+}
+
 func createInternalFavicon(outputBaseDir string) error {
 	// Favicon goes into outputBaseDir/images/favicon.ico, but is sourced from assets/favicon.ico
 	// The HTML template links to {{sitePath "/images/favicon.ico"}}
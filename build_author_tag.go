@@ -2,15 +2,16 @@ package main
 
 import (
 	"fmt"
-	"html/template"
-	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/gosimple/slug"
 )
 
-// generateAuthorPages generates list pages for each author
-func generateAuthorPages(cache *BuildCache, outputDirRoot string) error { // MODIFIED: Added outputDirRoot
+// addAuthorPages registers a generated list page for each author.
+// Pages are appended to cache.Pages up front, before any rendering starts,
+// so the parallel renderers only ever read the page set.
+func addAuthorPages(cache *BuildCache, outputDirRoot string) {
 	authors := make(map[string]bool)
 	for _, p := range cache.Pages {
 		if p.FrontMatter.Author != "" {
@@ -18,16 +19,19 @@ func generateAuthorPages(cache *BuildCache, outputDirRoot string) error { // MOD
 		}
 	}
 
-	for author := range authors {
-		if err := generateAuthorPage(cache, author, outputDirRoot); err != nil { // MODIFIED: Passed outputDirRoot
-			return err
+	// Spellings that share a slug share a page; the listing matches authors
+	// case-insensitively anyway, and two workers must never write one file.
+	seen := make(map[string]bool)
+	for _, author := range sortedKeys(authors) {
+		if s := slug.Make(author); !seen[s] {
+			seen[s] = true
+			cache.Pages = append(cache.Pages, authorPage(author, outputDirRoot))
 		}
 	}
-	return nil
 }
 
-// generateTagPages generates list pages for each tag
-func generateTagPages(cache *BuildCache, outputDirRoot string) error { // MODIFIED: Added outputDirRoot
+// addTagPages registers a generated list page for each tag.
+func addTagPages(cache *BuildCache, outputDirRoot string) {
 	tags := make(map[string]bool)
 	for _, p := range cache.Pages {
 		for _, tag := range p.FrontMatter.Tags {
@@ -35,207 +39,57 @@ func generateTagPages(cache *BuildCache, outputDirRoot string) error { // MODIFI
 		}
 	}
 
-	for tag := range tags {
-		if err := generateTagPage(cache, tag, outputDirRoot); err != nil { // MODIFIED: Passed outputDirRoot
-			return err
+	// Spellings that share a slug ("Go", "go") share a page.
+	seen := make(map[string]bool)
+	for _, tag := range sortedKeys(tags) {
+		if s := slug.Make(tag); !seen[s] {
+			seen[s] = true
+			cache.Pages = append(cache.Pages, tagPage(tag, outputDirRoot))
 		}
 	}
-	return nil
 }
 
-// generateAuthorPage generates a list page for a specific author
-func generateAuthorPage(cache *BuildCache, author string, outputDirRoot string) error { // MODIFIED: Added outputDirRoot
+// authorPage builds the pseudo list page for a specific author
+func authorPage(author string, outputDirRoot string) *PageData {
 	authorSlug := slug.Make(author)
-	dir := filepath.Join(outputDirRoot, "authors", authorSlug) // MODIFIED: Used outputDirRoot
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	outFile := filepath.Join(dir, "index.html")
-	f, err := os.Create(outFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	// Create a unique RelPath for this author page
-	authorRelPath := filepath.Join("authors", authorSlug, "index.md")
-	
 	pseudo := &PageData{
 		FrontMatter: PageFrontMatter{
 			Title:        fmt.Sprintf("Posts by %s", author),
 			Type:         "list",
 			AuthorFilter: []string{author}, // Filter by the current author
 		},
-		RelPath:   authorRelPath,
-		OutputDir: dir,
-	}
-	
-	// Add the pseudo page to the cache so listPagesInDirectory can find it
-	cache.Pages = append(cache.Pages, pseudo)
-
-	var menuItems []string
-	var menuTargets []string
-	for _, item := range cache.Config.Menu {
-		menuItems = append(menuItems, item.Title)
-		outDir := FindPageByRelPath(cache, item.Path)
-		if outDir == "" {
-			if item.Path == "index.md" {
-				menuTargets = append(menuTargets, "/")
-			} else {
-				menuTargets = append(menuTargets, "/")
-			}
-		} else {
-			menuTargets = append(menuTargets, "/"+outDir+"/")
-		}
+		// A unique RelPath so listPagesInDirectory can find it
+		RelPath:   filepath.ToSlash(filepath.Join("authors", authorSlug, "index.md")),
+		OutputDir: filepath.Join(outputDirRoot, "authors", authorSlug),
+		kind:      "author",
 	}
-
-	data := struct {
-		Config              *Config
-		Page                *PageData
-		MenuItems           []string
-		MenuTargets         []string
-		AlternativeCSSFiles []string
-		AlternativeJSFiles  []string
-	}{
-		Config:      cache.Config,
-		Page:        pseudo,
-		MenuItems:   menuItems,
-		MenuTargets: menuTargets,
-	}
-
-	if cache.Config.Website.AlternativeCSSDir != "" {
-		files, err := os.ReadDir(cache.Config.Website.AlternativeCSSDir)
-		if err == nil {
-			for _, file := range files {
-				if !file.IsDir() && filepath.Ext(file.Name()) == ".css" {
-					data.AlternativeCSSFiles = append(data.AlternativeCSSFiles, "/css/"+file.Name())
-				}
-			}
-		}
-	}
-
-	if cache.Config.Website.AlternativeJSDir != "" {
-		files, err := os.ReadDir(cache.Config.Website.AlternativeJSDir)
-		if err == nil {
-			for _, file := range files {
-				if !file.IsDir() && filepath.Ext(file.Name()) == ".js" {
-					data.AlternativeJSFiles = append(data.AlternativeJSFiles, "/js/"+file.Name())
-				}
-			}
-		}
-	}
-
-	tmpl := template.New("author")
-	tmpl = initTemplateFuncs(tmpl, cache, outputDirRoot) // MODIFIED: Passed cache and outputDirRoot as siteBuildRoot
-	tmpl, err = tmpl.Parse(htmlTemplate)
-	if err != nil {
-		return err
-	}
-	if err := tmpl.Execute(f, data); err != nil {
-		return err
-	}
-
-	fmt.Printf("Generated: %s\n", outFile)
-	return nil
+	pseudo.FrontMatterHash = hashBytes([]byte(pseudo.FrontMatter.Title))
+	return pseudo
 }
 
-// generateTagPage generates a list page for a specific tag
-func generateTagPage(cache *BuildCache, tag string, outputDirRoot string) error { // MODIFIED: Added outputDirRoot
+// tagPage builds the pseudo list page for a specific tag
+func tagPage(tag string, outputDirRoot string) *PageData {
 	tagSlug := slug.Make(tag)
-	dir := filepath.Join(outputDirRoot, "tags", tagSlug) // MODIFIED: Used outputDirRoot
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	outFile := filepath.Join(dir, "index.html")
-	f, err := os.Create(outFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	// Create a unique RelPath for this tag page
-	tagRelPath := filepath.Join("tags", tagSlug, "index.md")
-	
 	pseudo := &PageData{
 		FrontMatter: PageFrontMatter{
 			Title:     fmt.Sprintf("Posts tagged with %s", tag),
 			Type:      "list",
 			TagFilter: []string{tag}, // Filter by the current tag
 		},
-		RelPath:   tagRelPath,
-		OutputDir: dir,
-	}
-	
-	// Add the pseudo page to the cache so listPagesInDirectory can find it
-	cache.Pages = append(cache.Pages, pseudo)
-
-	data := struct {
-		Config              *Config
-		Page                *PageData
-		MenuItems           []string
-		MenuTargets         []string
-		AlternativeCSSFiles []string
-		AlternativeJSFiles  []string
-	}{
-		Config:      cache.Config,
-		Page:        pseudo,
-		MenuItems:   []string{}, // Will be populated below
-		MenuTargets: []string{}, // Will be populated below
+		// A unique RelPath so listPagesInDirectory can find it
+		RelPath:   filepath.ToSlash(filepath.Join("tags", tagSlug, "index.md")),
+		OutputDir: filepath.Join(outputDirRoot, "tags", tagSlug),
+		kind:      "tag",
 	}
+	pseudo.FrontMatterHash = hashBytes([]byte(pseudo.FrontMatter.Title))
+	return pseudo
+}
 
-	if cache.Config.Website.AlternativeCSSDir != "" {
-		files, err := os.ReadDir(cache.Config.Website.AlternativeCSSDir)
-		if err == nil {
-			for _, file := range files {
-				if !file.IsDir() && filepath.Ext(file.Name()) == ".css" {
-					data.AlternativeCSSFiles = append(data.AlternativeCSSFiles, "/css/"+file.Name())
-				}
-			}
-		}
-	}
-
-	if cache.Config.Website.AlternativeJSDir != "" {
-		files, err := os.ReadDir(cache.Config.Website.AlternativeJSDir)
-		if err == nil {
-			for _, file := range files {
-				if !file.IsDir() && filepath.Ext(file.Name()) == ".js" {
-					data.AlternativeJSFiles = append(data.AlternativeJSFiles, "/js/"+file.Name())
-				}
-			}
-		}
-	}
-
-	var menuItems []string
-	var menuTargets []string
-	for _, item := range cache.Config.Menu {
-		menuItems = append(menuItems, item.Title)
-		outDir := FindPageByRelPath(cache, item.Path)
-		if outDir == "" {
-			if item.Path == "index.md" {
-				menuTargets = append(menuTargets, "/")
-			} else {
-				menuTargets = append(menuTargets, "/")
-			}
-		} else {
-			menuTargets = append(menuTargets, "/"+outDir+"/")
-		}
-	}
-
-	data.MenuItems = menuItems
-	data.MenuTargets = menuTargets
-
-	tmpl := template.New("tag")
-	tmpl = initTemplateFuncs(tmpl, cache, outputDirRoot) // MODIFIED: Passed cache and outputDirRoot as siteBuildRoot
-	tmpl, err = tmpl.Parse(htmlTemplate)
-	if err != nil {
-		return err
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	if err := tmpl.Execute(f, data); err != nil {
-		return err
-	}
-
-	fmt.Printf("Generated: %s\n", outFile)
-	return nil
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Callouts as in Obsidian and GitHub: a block quote whose first line is
+// [!type], optionally followed by + or - to make it foldable (open or
+// closed) and by a title.
+var reCallout = regexp.MustCompile(`^\[!([A-Za-z][\w-]*)\]([+-]?)[ \t]*`)
+
+// calloutStyles are the callout types with their own colors in custom.css.
+// Other types look like a note.
+var calloutStyles = map[string]bool{
+	"note": true, "abstract": true, "info": true, "todo": true, "tip": true,
+	"success": true, "question": true, "warning": true, "failure": true,
+	"danger": true, "bug": true, "example": true, "quote": true,
+}
+
+// calloutAliases are the other names Obsidian accepts for a type.
+var calloutAliases = map[string]string{
+	"summary": "abstract", "tldr": "abstract",
+	"hint": "tip", "important": "tip",
+	"check": "success", "done": "success",
+	"help": "question", "faq": "question",
+	"caution": "warning", "attention": "warning",
+	"fail": "failure", "missing": "failure",
+	"error": "danger",
+	"cite":  "quote",
+}
+
+// callout replaces a block quote. Its children are a calloutTitle and,
+// unless the callout has only a title, a calloutContent.
+type callout struct {
+	ast.Container
+	Kind  string // lower case, as written
+	Style string // the type that decides the colors
+	Fold  string // "" for a plain callout, "+" open, "-" closed
+}
+
+type calloutTitle struct {
+	ast.Container
+}
+
+type calloutContent struct {
+	ast.Container
+}
+
+// transformCallouts turns the block quotes in doc that start with a
+// callout marker into callouts.
+func transformCallouts(doc ast.Node) {
+	var quotes []*ast.BlockQuote
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if q, ok := node.(*ast.BlockQuote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.GoToNext
+	})
+	// Outer quotes come first; moving their children keeps the parents of
+	// nested quotes up to date for replaceNode.
+	for _, q := range quotes {
+		makeCallout(q)
+	}
+}
+
+// calloutMarker returns the marker at the start of a paragraph, if any.
+func calloutMarker(node ast.Node) []string {
+	para, ok := node.(*ast.Paragraph)
+	if !ok || len(para.Children) == 0 {
+		return nil
+	}
+	text, ok := para.Children[0].(*ast.Text)
+	if !ok {
+		return nil
+	}
+	return reCallout.FindStringSubmatch(string(text.Literal))
+}
+
+func makeCallout(q *ast.BlockQuote) {
+	if len(q.Children) == 0 {
+		return
+	}
+	m := calloutMarker(q.Children[0])
+	if m == nil {
+		return
+	}
+
+	// The parser joins quotes that are only separated by a blank line, so
+	// a marker further down starts the next callout.
+	body := q.Children
+	var next *ast.BlockQuote
+	for i := 1; i < len(body); i++ {
+		if calloutMarker(body[i]) != nil {
+			next = &ast.BlockQuote{}
+			setChildren(next, body[i:])
+			body = body[:i]
+			break
+		}
+	}
+
+	kind := strings.ToLower(m[1])
+	style := kind
+	if alias, ok := calloutAliases[kind]; ok {
+		style = alias
+	}
+	if !calloutStyles[style] {
+		style = "note"
+	}
+	c := &callout{Kind: kind, Style: style, Fold: m[2]}
+
+	// The rest of the marker's line is the title.
+	first := body[0].(*ast.Paragraph)
+	marker := first.Children[0].(*ast.Text)
+	marker.Literal = marker.Literal[len(m[0]):]
+	titleNodes, rest := splitFirstLine(first.Children)
+	title := &calloutTitle{}
+	setChildren(title, titleNodes)
+	if strings.TrimSpace(nodeText(title)) == "" {
+		setChildren(title, []ast.Node{textNode(strings.ToUpper(kind[:1]) + kind[1:])})
+	}
+	parts := []ast.Node{title}
+
+	if len(rest) > 0 {
+		setChildren(first, rest)
+	} else {
+		body = body[1:]
+	}
+	if len(body) > 0 {
+		content := &calloutContent{}
+		setChildren(content, body)
+		parts = append(parts, content)
+	}
+	setChildren(c, parts)
+
+	if next != nil {
+		replaceNode(q, c, next)
+		makeCallout(next)
+		return
+	}
+	replaceNode(q, c)
+}
+
+// setChildren makes children the children of parent. Unlike
+// ast.AppendChild it keeps the children of nodes moved from elsewhere in
+// the tree.
+func setChildren(parent ast.Node, children []ast.Node) {
+	parent.SetChildren(append([]ast.Node(nil), children...))
+	for _, c := range children {
+		c.SetParent(parent)
+	}
+}
+
+// splitFirstLine splits the inline nodes of a paragraph after the first
+// line break.
+func splitFirstLine(nodes []ast.Node) ([]ast.Node, []ast.Node) {
+	for i, n := range nodes {
+		switch n := n.(type) {
+		case *ast.Hardbreak:
+			return nodes[:i], nodes[i+1:]
+		case *ast.Text:
+			before, after, found := bytes.Cut(n.Literal, []byte("\n"))
+			if !found {
+				continue
+			}
+			head := append(append([]ast.Node(nil), nodes[:i]...), textNode(strings.TrimRight(string(before), " \t")))
+			tail := nodes[i+1:]
+			if len(after) > 0 {
+				tail = append([]ast.Node{textNode(string(after))}, tail...)
+			}
+			return head, tail
+		}
+	}
+	return nodes, nil
+}
+
+// renderCallout writes callouts; foldable ones are <details> elements, so
+// they open and close without JavaScript.
+func renderCallout(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	switch n := node.(type) {
+	case *callout:
+		tag := "div"
+		if n.Fold != "" {
+			tag = "details"
+		}
+		if !entering {
+			fmt.Fprintf(w, "</%s>\n", tag)
+			return ast.GoToNext, true
+		}
+		open := ""
+		if n.Fold == "+" {
+			open = " open"
+		}
+		fmt.Fprintf(w, "<%s class=\"callout callout-%s\" data-callout=\"%s\"%s>\n", tag, n.Style, n.Kind, open)
+	case *calloutTitle:
+		tag := "div"
+		if parent, ok := n.Parent.(*callout); ok && parent.Fold != "" {
+			tag = "summary"
+		}
+		if entering {
+			fmt.Fprintf(w, "<%s class=\"callout-title\">", tag)
+		} else {
+			fmt.Fprintf(w, "</%s>\n", tag)
+		}
+	case *calloutContent:
+		if entering {
+			io.WriteString(w, "<div class=\"callout-content\">\n")
+		} else {
+			io.WriteString(w, "</div>\n")
+		}
+	default:
+		return ast.GoToNext, false
+	}
+	return ast.GoToNext, true
+}
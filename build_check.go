@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// reportProblems prints every broken link, missing image, dangling menu
+// path and invalid code block attribute found during the build, with its
+// source file and line. With strict set, any problem fails the build.
+func reportProblems(cache *BuildCache, configPath string, strict bool) error {
+	var problems []*sourceError
+	for _, p := range cache.Pages {
+		problems = append(problems, p.problems...)
+		if img := p.FrontMatter.Image; img != "" && isLocalDest(img) {
+			if !publishFile(cache, strings.TrimPrefix(img, "/")) {
+				problems = append(problems, &sourceError{
+					File: p.RelPath,
+					Line: p.frontMatterLines["image"],
+					Err:  fmt.Errorf("missing front matter image %q", img),
+				})
+			}
+		}
+	}
+	problems = append(problems, menuProblems(cache, configPath)...)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	seen := make(map[string]bool)
+	for _, p := range problems {
+		msg := p.Error()
+		if !seen[msg] {
+			seen[msg] = true
+			fmt.Printf("Warning: %s\n", msg)
+		}
+	}
+	if strict {
+		return fmt.Errorf("%d problem(s), failing because of --strict", len(seen))
+	}
+	return nil
+}
+
+// menuProblems reports menu entries whose path matches no page.
+func menuProblems(cache *BuildCache, configPath string) []*sourceError {
+	lines := menuPathLines(configPath)
+	var problems []*sourceError
+	for i, item := range cache.Config.Menu {
+		found := false
+		for _, p := range cache.Pages {
+			if p.RelPath == item.Path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			line := 0
+			if i < len(lines) {
+				line = lines[i]
+			}
+			problems = append(problems, &sourceError{
+				File: configPath,
+				Line: line,
+				Err:  fmt.Errorf("menu %q points to missing page %q", item.Title, item.Path),
+			})
+		}
+	}
+	return problems
+}
+
+// menuPathLines returns the line of each menu entry's path in config.yaml,
+// in order. Errors yield no lines; readConfig has already reported them.
+func menuPathLines(configPath string) []int {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "menu" {
+			continue
+		}
+		var lines []int
+		for _, entry := range root.Content[i+1].Content {
+			line := entry.Line
+			for j := 0; j+1 < len(entry.Content); j += 2 {
+				if entry.Content[j].Value == "path" {
+					line = entry.Content[j+1].Line
+				}
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+	return nil
+}
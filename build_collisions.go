@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// outputClaims maps each file the build will write, relative to the output
+// directory, to the sources that produce it.
+type outputClaims map[string][]string
+
+func (c outputClaims) claim(file, source string) {
+	for _, s := range c[file] {
+		if s == source {
+			return
+		}
+	}
+	c[file] = append(c[file], source)
+}
+
+// checkOutputCollisions fails the build when two sources would write the
+// same output file: pages whose titles slug alike, a page inside the
+// generated tags/ or authors/ directories, tags or authors that differ but
+// share a slug, or a page on top of a static asset, redirect stub or
+// generated file.
+// It runs after all pages, including tag and author pages, are registered
+// and static assets are copied.
+func checkOutputCollisions(cache *BuildCache, outputDir string, redirects []redirect) error {
+	claims := make(outputClaims)
+	var problems []string
+
+	hasKind := make(map[string]bool)
+	for _, p := range cache.Pages {
+		hasKind[p.kind] = true
+	}
+
+	for _, p := range allPages(cache) {
+		rel := relOutput(outputDir, p.OutputDir)
+		source := pageSource(p)
+		claims.claim(path.Join(rel, "index.html"), source)
+		if dir := pageFeedDir(cache, p); dir != "" {
+			for _, file := range enabledFeedFiles(cache) {
+				claims.claim(path.Join(strings.Trim(dir, "/"), file), "feeds of "+source)
+			}
+		}
+		if p.kind != "" || p.isExtraPage() {
+			continue
+		}
+		for _, reserved := range []string{"tag", "author"} {
+			dir := reserved + "s"
+			if hasKind[reserved] && (rel == dir || strings.HasPrefix(rel, dir+"/")) {
+				problems = append(problems, fmt.Sprintf("%s: writes to %s/, which is reserved for the generated %s pages", p.RelPath, rel, reserved))
+			}
+		}
+	}
+
+	for _, file := range enabledFeedFiles(cache) {
+		claims.claim(file, "site feed")
+	}
+	for _, file := range searchIndexFiles(cache) {
+		claims.claim(file, "search index")
+	}
+	for _, r := range redirects {
+		claims.claim(r.stubFile(), r.Source)
+	}
+	if cache.Config.RedirectsFile {
+		claims.claim(redirectsFileName, "redirectsFile")
+	}
+	claims.claim("404.html", "404 page")
+	if boolOr(cache.Config.Sitemap.Enabled, true) {
+		claims.claim("sitemap.xml", "sitemap")
+	}
+	if boolOr(cache.Config.Robots.Enabled, true) {
+		claims.claim("robots.txt", "robots.txt")
+	}
+	if extractDomain(cache.Config.Website.URL) != "" {
+		claims.claim("CNAME", "CNAME")
+	}
+	for _, dir := range []string{"css", "js", "images"} {
+		_ = filepath.WalkDir(filepath.Join(outputDir, dir), func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				claims.claim(relOutput(outputDir, p), "static asset")
+			}
+			return nil
+		})
+	}
+
+	files := make([]string, 0, len(claims))
+	for file, sources := range claims {
+		if len(sources) > 1 {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		problems = append(problems, fmt.Sprintf("%s is written by:\n    %s", file, strings.Join(claims[file], "\n    ")))
+	}
+
+	problems = append(problems, slugSpellingProblems(cache, "tag", func(p *PageData) []string { return p.FrontMatter.Tags })...)
+	problems = append(problems, slugSpellingProblems(cache, "author", func(p *PageData) []string {
+		if p.FrontMatter.Author == "" {
+			return nil
+		}
+		return []string{p.FrontMatter.Author}
+	})...)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("output path collisions:\n  %s", strings.Join(problems, "\n  "))
+}
+
+// slugSpellingProblems reports tags (or authors) that share a slug, and so
+// a page, without being the same name in a different case: "C++" and "C".
+func slugSpellingProblems(cache *BuildCache, kind string, names func(*PageData) []string) []string {
+	usedBy := make(map[string]map[string][]string) // slug => name => pages
+	for _, p := range cache.Pages {
+		if p.kind != "" {
+			continue
+		}
+		for _, name := range names(p) {
+			name = strings.TrimSpace(name)
+			s := slug.Make(name)
+			if usedBy[s] == nil {
+				usedBy[s] = make(map[string][]string)
+			}
+			usedBy[s][name] = append(usedBy[s][name], p.RelPath)
+		}
+	}
+
+	var problems []string
+	for _, s := range sortedKeys(usedBy) {
+		spellings := usedBy[s]
+		var distinct []string
+		for _, name := range sortedKeys(spellings) {
+			same := false
+			for _, d := range distinct {
+				if strings.EqualFold(d, name) {
+					same = true
+					break
+				}
+			}
+			if !same {
+				distinct = append(distinct, name)
+			}
+		}
+		if len(distinct) < 2 {
+			continue
+		}
+		var lines []string
+		for _, name := range sortedKeys(spellings) {
+			lines = append(lines, fmt.Sprintf("%s %q in %s", kind, name, strings.Join(spellings[name], ", ")))
+		}
+		problems = append(problems, fmt.Sprintf("%ss/%s/index.html is written by:\n    %s", kind, s, strings.Join(lines, "\n    ")))
+	}
+	return problems
+}
+
+// pageSource names the source of a page in collision reports.
+func pageSource(p *PageData) string {
+	switch {
+	case p.kind == "tag" && len(p.FrontMatter.TagFilter) > 0:
+		return fmt.Sprintf("tag page %q", p.FrontMatter.TagFilter[0])
+	case p.kind == "author" && len(p.FrontMatter.AuthorFilter) > 0:
+		return fmt.Sprintf("author page %q", p.FrontMatter.AuthorFilter[0])
+	case p.kind == "search":
+		return "search page"
+	}
+	return p.RelPath
+}
+
+// enabledFeedFiles lists the feed file names written for each feed.
+func enabledFeedFiles(cache *BuildCache) []string {
+	var files []string
+	for _, f := range []struct{ format, file string }{
+		{"rss", rssFileName},
+		{"atom", atomFileName},
+		{"json", jsonFeedFileName},
+	} {
+		if cache.Config.Feeds.Enabled(f.format) {
+			files = append(files, f.file)
+		}
+	}
+	return files
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -22,15 +23,124 @@ type Config struct {
 		AlternativeCSSDir  string `yaml:"alternativeCSSDir,omitempty"`
 		AlternativeJSDir   string `yaml:"alternativeJSDir,omitempty"`
 		AlternativeFavicon string `yaml:"alternativeFavicon,omitempty"`
+		Timezone           string `yaml:"timezone,omitempty"` // IANA name, e.g. "Europe/Vienna"; UTC when empty
 	} `yaml:"website"`
 	Menu []struct {
 		Title string `yaml:"title"`
 		Path  string `yaml:"path"`
 	} `yaml:"menu"`
 
+	Feeds   FeedsConfig   `yaml:"feeds,omitempty"`
+	Sitemap SitemapConfig `yaml:"sitemap,omitempty"`
+	Robots  RobotsConfig  `yaml:"robots,omitempty"`
+	Check   CheckConfig   `yaml:"check,omitempty"`
+	Search  SearchConfig  `yaml:"search,omitempty"`
+	TOC     TOCConfig     `yaml:"toc,omitempty"`
+
+	Highlight HighlightConfig `yaml:"highlight,omitempty"`
+
+	// Permalinks maps a directory ("blog", or "/" for the whole site) to the
+	// URL pattern of its pages, e.g. "/:year/:month/:slug/".
+	Permalinks map[string]string `yaml:"permalinks,omitempty"`
+
+	// Redirects maps old site paths to a new site path, a .md file or a URL.
+	// RedirectsFile also writes them to _redirects for Netlify/Cloudflare.
+	Redirects     map[string]string `yaml:"redirects,omitempty"`
+	RedirectsFile bool              `yaml:"redirectsFile,omitempty"`
+
+	// Paginate is the default number of pages per list page; a list's own
+	// paginate front matter wins. 0 shows every page on one list page.
+	Paginate int `yaml:"paginate,omitempty"`
+
 	Quacker *QuackerConfig `yaml:"quacker,omitempty"`
 }
 
+// CheckConfig controls krems --check.
+type CheckConfig struct {
+	External ExternalCheckConfig `yaml:"external,omitempty"`
+}
+
+// ExternalCheckConfig controls krems --check --external.
+type ExternalCheckConfig struct {
+	Timeout   string   `yaml:"timeout,omitempty"`   // per request, e.g. "10s"
+	PerHost   int      `yaml:"perHost,omitempty"`   // concurrent requests to one host
+	CacheTTL  string   `yaml:"cacheTTL,omitempty"`  // how long a working URL is trusted, e.g. "24h"; "0s" disables the cache
+	CacheFile string   `yaml:"cacheFile,omitempty"` // default .krems-linkcache.json
+	Ignore    []string `yaml:"ignore,omitempty"`    // URL patterns never requested; * matches anything
+	Allow     []string `yaml:"allow,omitempty"`     // if set, only URLs matching these are requested
+}
+
+// FeedsConfig controls the site feeds.
+type FeedsConfig struct {
+	Formats      []string `yaml:"formats,omitempty"`      // any of rss, atom, json; all three when empty
+	FullContent  bool     `yaml:"fullContent,omitempty"`  // include the rendered page, not just the description
+	Limit        int      `yaml:"limit,omitempty"`        // newest items per feed; 0 means all
+	SectionFeeds bool     `yaml:"sectionFeeds,omitempty"` // a feed next to every list page
+	TagFeeds     bool     `yaml:"tagFeeds,omitempty"`     // /tags/<slug>/rss.xml etc.
+	AuthorFeeds  bool     `yaml:"authorFeeds,omitempty"`  // /authors/<slug>/rss.xml etc.
+}
+
+// Enabled reports whether feeds are written in format ("rss", "atom" or "json").
+func (f FeedsConfig) Enabled(format string) bool {
+	if len(f.Formats) == 0 {
+		return true
+	}
+	for _, v := range f.Formats {
+		if strings.EqualFold(strings.TrimSpace(v), format) {
+			return true
+		}
+	}
+	return false
+}
+
+// SitemapConfig controls sitemap.xml. It is generated unless enabled is false.
+type SitemapConfig struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+}
+
+// HighlightConfig controls syntax highlighting of fenced code blocks. It
+// is on unless enabled is false.
+type HighlightConfig struct {
+	Enabled     *bool  `yaml:"enabled,omitempty"`
+	Style       string `yaml:"style,omitempty"`       // chroma style name, default "github"
+	DarkStyle   string `yaml:"darkStyle,omitempty"`   // used when the reader prefers a dark color scheme
+	LineNumbers bool   `yaml:"lineNumbers,omitempty"` // number every block unless its fence says linenos=false
+}
+
+// IsEnabled reports whether code blocks are highlighted.
+func (h HighlightConfig) IsEnabled() bool {
+	return boolOr(h.Enabled, true)
+}
+
+// TOCConfig sets the defaults for tables of contents; pages override them
+// with toc, tocMinLevel and tocMaxLevel front matter.
+type TOCConfig struct {
+	Enabled  bool `yaml:"enabled,omitempty"`
+	MinLevel int  `yaml:"minLevel,omitempty"` // default 2
+	MaxLevel int  `yaml:"maxLevel,omitempty"` // default 3
+}
+
+// SearchConfig controls the search index and the /search/ page. Search is
+// off unless enabled is true.
+type SearchConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	Shards  bool `yaml:"shards,omitempty"` // one index file per top-level directory
+}
+
+// RobotsConfig controls robots.txt. It is generated unless enabled is false.
+type RobotsConfig struct {
+	Enabled  *bool    `yaml:"enabled,omitempty"`
+	Disallow []string `yaml:"disallow,omitempty"` // site paths, e.g. "/private/"
+}
+
+// boolOr returns *b, or def when the option was not set in config.yaml.
+func boolOr(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
 func readConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
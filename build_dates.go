@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // website.timezone must work without a system zoneinfo database
+)
+
+// dateLayouts are the front matter date formats without a zone, tried in
+// order after RFC 3339. They are read in the site's timezone.
+var dateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDate reads a front matter date: RFC 3339 ("2025-06-04T09:24:00+02:00"),
+// a date and time without zone ("2025-06-04T09:24") or a date ("2025-06-04").
+// Values without a zone are taken to be in loc.
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (use 2006-01-02, 2006-01-02T15:04 or RFC 3339)", value)
+}
+
+// siteLocation returns the timezone dates without a zone are read in:
+// website.timezone from config.yaml, or UTC.
+func siteLocation(cfg *Config) (*time.Location, error) {
+	if cfg.Website.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(cfg.Website.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid website.timezone %q: %w", cfg.Website.Timezone, err)
+	}
+	return loc, nil
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feed file names, written next to each other in the feed's directory.
+const (
+	rssFileName      = "rss.xml"
+	atomFileName     = "atom.xml"
+	jsonFeedFileName = "feed.json"
+)
+
+// feed is one set of items rendered into every enabled feed format.
+type feed struct {
+	Title       string
+	Description string
+	HomeURL     string // page the feed belongs to
+	Dir         string // site path of the directory the feed files go in, e.g. "/"
+	Items       []feedItem
+}
+
+// feedItem is a page as it appears in a feed.
+type feedItem struct {
+	Title       string
+	URL         string
+	Description string
+	Date        time.Time
+	Updated     time.Time // front matter updated, or Date
+	Author      string
+	Tags        []string
+	Image       *feedImage
+	Content     string // full HTML with absolute URLs, when feeds.fullContent is set
+}
+
+type feedImage struct {
+	URL      string
+	MimeType string
+	Size     int64
+}
+
+// generateFeeds => outputDirRoot/rss.xml, atom.xml and feed.json, plus a
+// feed next to every list, tag and author page when enabled in config.yaml.
+func generateFeeds(cache *BuildCache, outputDirRoot string) error {
+	name := cache.Config.Website.Name
+	site := &feed{
+		Title:       name,
+		Description: fmt.Sprintf("RSS feed for %s", name),
+		HomeURL:     absoluteURL(cache, "/"),
+		Dir:         "/",
+		Items:       feedItems(cache, cache.Pages),
+	}
+	if _, err := writeFeed(cache, outputDirRoot, site); err != nil {
+		return err
+	}
+
+	for _, p := range cache.Pages {
+		dir := pageFeedDir(cache, p)
+		if dir == "" {
+			continue
+		}
+		f := &feed{
+			Title:       fmt.Sprintf("%s: %s", name, p.FrontMatter.Title),
+			Description: fmt.Sprintf("RSS feed for %s on %s", p.FrontMatter.Title, name),
+			HomeURL:     absoluteURL(cache, dir),
+			Dir:         dir,
+			Items:       feedItems(cache, collectListedPages(cache, p)),
+		}
+		written, err := writeFeed(cache, outputDirRoot, f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.RelPath, err)
+		}
+		// Tag and author feeds come and go with their pages; recording them
+		// lets the next build remove the ones that are no longer generated.
+		cache.NextManifest.recordGenerated(p.RelPath+"#feeds", written)
+	}
+	return nil
+}
+
+// pageFeedDir returns the site path of the directory holding p's own feeds,
+// or "" when p gets none. Only list pages get feeds; the root list page is
+// covered by the site feed.
+func pageFeedDir(cache *BuildCache, p *PageData) string {
+	if p.FrontMatter.Type != "list" || p.isExtraPage() {
+		return ""
+	}
+	cfg := cache.Config.Feeds
+	switch p.kind {
+	case "tag":
+		if !cfg.TagFeeds {
+			return ""
+		}
+	case "author":
+		if !cfg.AuthorFeeds {
+			return ""
+		}
+	case "":
+		if !cfg.SectionFeeds {
+			return ""
+		}
+	default:
+		return ""
+	}
+	dir := pagePath(cache, p)
+	if dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// feedItems turns the dated pages into feed items, newest first,
+// keeping at most feeds.limit of them.
+func feedItems(cache *BuildCache, pages []*PageData) []feedItem {
+	var dated []*PageData
+	for _, p := range pages {
+		if !p.FrontMatter.ParsedDate.IsZero() {
+			dated = append(dated, p)
+		}
+	}
+	sort.Slice(dated, func(i, j int) bool {
+		return dated[i].FrontMatter.ParsedDate.After(dated[j].FrontMatter.ParsedDate)
+	})
+	if limit := cache.Config.Feeds.Limit; limit > 0 && len(dated) > limit {
+		dated = dated[:limit]
+	}
+
+	items := make([]feedItem, 0, len(dated))
+	for _, p := range dated {
+		item := feedItem{
+			Title:       p.FrontMatter.Title,
+			URL:         absoluteURL(cache, pagePath(cache, p)),
+			Description: p.FrontMatter.Description,
+			Date:        p.FrontMatter.ParsedDate,
+			Updated:     p.FrontMatter.ParsedDate,
+			Author:      p.FrontMatter.Author,
+			Tags:        p.FrontMatter.Tags,
+		}
+		if !p.FrontMatter.ParsedUpdated.IsZero() {
+			item.Updated = p.FrontMatter.ParsedUpdated
+		}
+		if p.FrontMatter.Image != "" {
+			img := strings.TrimPrefix(p.FrontMatter.Image, "/")
+			item.Image = &feedImage{
+				URL:      absoluteURL(cache, "/"+img),
+				MimeType: mimeTypeFor(img),
+			}
+			if info, err := os.Stat(filepath.FromSlash(img)); err == nil {
+				item.Image.Size = info.Size()
+			}
+		}
+		if cache.Config.Feeds.FullContent {
+			item.Content = absolutizeURLs(string(p.HTMLContent), item.URL)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// absolutizeURLs rewrites every href and src in content to an absolute URL,
+// resolving relative ones against pageURL, so feed readers can follow them.
+func absolutizeURLs(content, pageURL string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return content
+	}
+	return rewriteURLAttrs(content, func(_, value string) string {
+		ref, err := url.Parse(value)
+		if err != nil {
+			return value
+		}
+		return base.ResolveReference(ref).String()
+	})
+}
+
+// mimeTypeFor guesses a MIME type from a file extension.
+func mimeTypeFor(name string) string {
+	t := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if t == "" {
+		return "application/octet-stream"
+	}
+	if i := strings.Index(t, ";"); i != -1 {
+		t = t[:i]
+	}
+	return t
+}
+
+// writeFeed writes f in every format enabled in config.yaml.
+func writeFeed(cache *BuildCache, outputDirRoot string, f *feed) ([]string, error) {
+	dir := filepath.Join(outputDirRoot, filepath.FromSlash(strings.Trim(f.Dir, "/")))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var written []string
+	writers := []struct {
+		format string
+		file   string
+		render func(*BuildCache, *feed) ([]byte, error)
+	}{
+		{"rss", rssFileName, renderRSS},
+		{"atom", atomFileName, renderAtom},
+		{"json", jsonFeedFileName, renderJSONFeed},
+	}
+	for _, w := range writers {
+		if !cache.Config.Feeds.Enabled(w.format) {
+			continue
+		}
+		out, err := w.render(cache, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s feed: %w", w.format, err)
+		}
+		path := filepath.Join(dir, w.file)
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			return nil, err
+		}
+		written = append(written, relOutput(outputDirRoot, path))
+		fmt.Printf("Generated: %s\n", path)
+	}
+	return written, nil
+}
+
+// feedURL is the absolute URL of one of f's files.
+func feedURL(cache *BuildCache, f *feed, file string) string {
+	return absoluteURL(cache, strings.TrimSuffix(f.Dir, "/")+"/"+file)
+}
+
+// RSS 2.0
+
+type rssDoc struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	SelfLink    atomLink  `xml:"atom:link"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	Description string        `xml:"description"`
+	PubDate     string        `xml:"pubDate"`
+	Creator     string        `xml:"dc:creator,omitempty"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+	Content     *rssCData     `xml:"content:encoded"`
+}
+
+type rssCData struct {
+	Value string `xml:",cdata"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func renderRSS(cache *BuildCache, f *feed) ([]byte, error) {
+	doc := rssDoc{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.HomeURL,
+			Description: f.Description,
+			SelfLink:    atomLink{Href: feedURL(cache, f, rssFileName), Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	for _, it := range f.Items {
+		item := rssItem{
+			Title:       it.Title,
+			Link:        it.URL,
+			GUID:        rssGUID{IsPermaLink: "true", Value: it.URL},
+			Description: it.Description,
+			PubDate:     it.Date.Format(time.RFC1123Z),
+			Creator:     it.Author,
+			Categories:  it.Tags,
+		}
+		if it.Image != nil {
+			item.Enclosure = &rssEnclosure{URL: it.Image.URL, Length: it.Image.Size, Type: it.Image.MimeType}
+		}
+		if it.Content != "" {
+			item.Content = &rssCData{Value: it.Content}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return marshalXML(doc)
+}
+
+// Atom 1.0
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+func renderAtom(cache *BuildCache, f *feed) ([]byte, error) {
+	doc := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Title:   f.Title,
+		ID:      f.HomeURL,
+		Updated: feedUpdated(f).Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feedURL(cache, f, atomFileName), Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HomeURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, it := range f.Items {
+		entry := atomEntry{
+			Title:     it.Title,
+			ID:        it.URL,
+			Links:     []atomLink{{Href: it.URL, Rel: "alternate", Type: "text/html"}},
+			Published: it.Date.Format(time.RFC3339),
+			Updated:   it.Updated.Format(time.RFC3339),
+		}
+		if it.Author != "" {
+			entry.Author = &atomPerson{Name: it.Author}
+		}
+		for _, tag := range it.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if it.Description != "" {
+			entry.Summary = &atomText{Type: "text", Value: it.Description}
+		}
+		if it.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: it.Content}
+		}
+		if it.Image != nil {
+			entry.Links = append(entry.Links, atomLink{Href: it.Image.URL, Rel: "enclosure", Type: it.Image.MimeType, Length: it.Image.Size})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalXML(doc)
+}
+
+// feedUpdated is the date of the newest item, or the zero Unix time for an
+// empty feed so the output does not change between identical builds.
+func feedUpdated(f *feed) time.Time {
+	if len(f.Items) == 0 {
+		return time.Unix(0, 0).UTC()
+	}
+	return f.Items[0].Date
+}
+
+// JSON Feed 1.1
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	Summary       string               `json:"summary,omitempty"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+func renderJSONFeed(cache *BuildCache, f *feed) ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     feedURL(cache, f, jsonFeedFileName),
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, it := range f.Items {
+		item := jsonFeedItem{
+			ID:            it.URL,
+			URL:           it.URL,
+			Title:         it.Title,
+			Summary:       it.Description,
+			ContentHTML:   it.Content,
+			DatePublished: it.Date.Format(time.RFC3339),
+			Tags:          it.Tags,
+		}
+		if !it.Updated.Equal(it.Date) {
+			item.DateModified = it.Updated.Format(time.RFC3339)
+		}
+		if it.Author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: it.Author}}
+		}
+		if it.Image != nil {
+			item.Image = it.Image.URL
+			item.Attachments = []jsonFeedAttachment{{URL: it.Image.URL, MimeType: it.Image.MimeType, SizeInBytes: it.Image.Size}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func marshalXML(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
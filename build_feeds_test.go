@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+)
+
+func TestAbsolutizeURLs(t *testing.T) {
+	const pageURL = "https://example.com/base/blog/f/"
+	tests := []struct {
+		name, in, want string
+	}{
+		{"double quoted", `<a href="../g/">g</a>`, `<a href="https://example.com/base/blog/g/">g</a>`},
+		{"single quoted", `<img src='pic.png' alt="p">`, `<img src="https://example.com/base/blog/f/pic.png" alt="p">`},
+		{"unquoted", `<a class=x href=/about/>a</a>`, `<a class=x href="https://example.com/about/">a</a>`},
+		{"escaped query", `<a href="?a=1&amp;b=2">q</a>`, `<a href="https://example.com/base/blog/f/?a=1&amp;b=2">q</a>`},
+		{"data attributes", `<img data-src="lazy.png" src="a.png">`, `<img data-src="lazy.png" src="https://example.com/base/blog/f/a.png">`},
+		{"attribute in a value", `<a title="href=x" href="y">y</a>`, `<a title="href=x" href="https://example.com/base/blog/f/y">y</a>`},
+		{"prose", `<p>Set src=main.go in the config.</p>`, `<p>Set src=main.go in the config.</p>`},
+	}
+	for _, tt := range tests {
+		if got := absolutizeURLs(tt.in, pageURL); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	// HTML shown in a code block is text, not markup.
+	code := string(markdown.ToHTML([]byte("Code:\n\n    <a href=\"x.html\">plain</a>\n\n`<img src=y.png>`\n"), nil, nil))
+	if got := absolutizeURLs(code, pageURL); got != code {
+		t.Errorf("code block changed:\n got %s\nwant %s", got, code)
+	}
+	if !strings.Contains(code, "plain") {
+		t.Fatalf("unexpected rendering: %s", code)
+	}
+}
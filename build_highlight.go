@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// syntaxCSSFile holds the highlighting style, relative to the output directory.
+const syntaxCSSFile = "css/syntax.css"
+
+const defaultHighlightStyle = "github"
+
+// fenceOptions are the attributes after the language of a fenced code
+// block: ```go {linenos=true hl=3-5}
+type fenceOptions struct {
+	lang      string
+	lineNos   bool
+	table     bool     // line numbers in a separate column, so copying skips them
+	startLine int      // number of the first line
+	hl        [][2]int // highlighted line ranges, counted from startLine
+}
+
+// parseFenceInfo splits a code fence info string into the language and
+// the {key=value ...} attributes. Unknown keys are ignored.
+func parseFenceInfo(cfg HighlightConfig, info string) (fenceOptions, error) {
+	opts := fenceOptions{lineNos: cfg.LineNumbers, startLine: 1}
+	info = strings.TrimSpace(info)
+	attrs := ""
+	if i := strings.Index(info, "{"); i >= 0 {
+		attrs = strings.TrimSuffix(strings.TrimSpace(info[i+1:]), "}")
+		info = info[:i]
+	}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		opts.lang = fields[0]
+	}
+
+	for _, attr := range strings.FieldsFunc(attrs, func(r rune) bool { return r == ' ' || r == '\t' }) {
+		key, value, _ := strings.Cut(attr, "=")
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "linenos":
+			switch value {
+			case "true", "inline":
+				opts.lineNos, opts.table = true, false
+			case "table":
+				opts.lineNos, opts.table = true, true
+			case "false":
+				opts.lineNos = false
+			default:
+				return opts, fmt.Errorf("linenos=%s: use true, table or false", value)
+			}
+		case "linenostart":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, fmt.Errorf("linenostart=%s: not a number", value)
+			}
+			opts.startLine = n
+		case "hl", "hl_lines":
+			for _, r := range strings.Split(value, ",") {
+				from, to, isRange := strings.Cut(strings.TrimSpace(r), "-")
+				a, err := strconv.Atoi(from)
+				b := a
+				if err == nil && isRange {
+					b, err = strconv.Atoi(to)
+				}
+				if err != nil {
+					return opts, fmt.Errorf("hl=%s: use lines and ranges like 3-5,7", value)
+				}
+				opts.hl = append(opts.hl, [2]int{a, b})
+			}
+		}
+	}
+	return opts, nil
+}
+
+// codeBlockRenderer returns a render hook that highlights fenced code
+// blocks with a known language, or any block with fence attributes. Other
+// blocks keep the plain <pre><code> output.
+func codeBlockRenderer(cache *BuildCache, page *PageData) func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	cfg := cache.Config.Highlight
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		block, ok := node.(*ast.CodeBlock)
+		if !ok || !cfg.IsEnabled() {
+			return ast.GoToNext, false
+		}
+		opts, err := parseFenceInfo(cfg, string(block.Info))
+		if err != nil {
+			page.addProblem(string(block.Info), "code block: %v", err)
+		}
+		lexer := lexers.Get(opts.lang)
+		if lexer == nil {
+			if !strings.Contains(string(block.Info), "{") {
+				return ast.GoToNext, false
+			}
+			lexer = lexers.Fallback
+		}
+		iterator, err := chroma.Coalesce(lexer).Tokenise(nil, string(block.Literal))
+		if err != nil {
+			return ast.GoToNext, false
+		}
+
+		formatter := chromahtml.New(
+			chromahtml.WithClasses(true),
+			chromahtml.WithLineNumbers(opts.lineNos),
+			chromahtml.LineNumbersInTable(opts.table),
+			chromahtml.BaseLineNumber(opts.startLine),
+			chromahtml.HighlightLines(opts.hl),
+		)
+		var buf bytes.Buffer
+		if err := formatter.Format(&buf, styles.Fallback, iterator); err != nil {
+			return ast.GoToNext, false
+		}
+		fmt.Fprintf(w, "<div class=\"highlight\">%s</div>\n", buf.Bytes())
+		return ast.GoToNext, true
+	}
+}
+
+// writeSyntaxCSS writes the classes of the highlighting style, and of the
+// dark style for readers who prefer a dark color scheme, to css/syntax.css.
+func writeSyntaxCSS(cfg HighlightConfig, outputDir string) error {
+	light, err := highlightStyle(cfg.Style, defaultHighlightStyle)
+	if err != nil {
+		return err
+	}
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	var buf bytes.Buffer
+	if err := formatter.WriteCSS(&buf, light); err != nil {
+		return err
+	}
+	if cfg.DarkStyle != "" {
+		dark, err := highlightStyle(cfg.DarkStyle, "")
+		if err != nil {
+			return err
+		}
+		buf.WriteString("@media (prefers-color-scheme: dark) {\n")
+		if err := formatter.WriteCSS(&buf, dark); err != nil {
+			return err
+		}
+		buf.WriteString("}\n")
+	}
+
+	file := filepath.Join(outputDir, filepath.FromSlash(syntaxCSSFile))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Created internal: %s\n", file)
+	return nil
+}
+
+// highlightStyle looks up a chroma style by name.
+func highlightStyle(name, def string) (*chroma.Style, error) {
+	if name == "" {
+		name = def
+	}
+	style, ok := styles.Registry[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %q (see https://xyproto.github.io/splash/docs/)", name)
+	}
+	return style, nil
+}
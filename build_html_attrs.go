@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// reStartTag matches a start tag with its attributes. Text, comments and
+	// escaped markup, such as &lt;a href="x"&gt; in a code block, are not
+	// tags, and quoted values may contain '>'.
+	reStartTag = regexp.MustCompile(`<[A-Za-z][^\s/>]*(?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'>]+))?|\s*/)*\s*>`)
+
+	// reHTMLAttr matches one attribute inside a start tag: the name and the
+	// double quoted, single quoted or unquoted value.
+	reHTMLAttr = regexp.MustCompile(`\s([^\s"'>/=]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+)
+
+// htmlAttr is an attribute of a start tag in a piece of HTML.
+type htmlAttr struct {
+	Name       string // lower case
+	Value      string // unquoted and unescaped
+	Start, End int    // byte offsets of name=value in the HTML
+}
+
+// htmlAttrs returns the attributes of every start tag in s, in order.
+func htmlAttrs(s string) []htmlAttr {
+	var attrs []htmlAttr
+	for _, tag := range reStartTag.FindAllStringIndex(s, -1) {
+		// Skip the tag name, which the attribute pattern would match too.
+		from := tag[0] + 1 + strings.IndexFunc(s[tag[0]+1:tag[1]], func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' || r == '/' || r == '>'
+		})
+		for _, m := range reHTMLAttr.FindAllStringSubmatchIndex(s[from:tag[1]], -1) {
+			a := htmlAttr{Name: strings.ToLower(s[from+m[2] : from+m[3]]), Start: from + m[2], End: from + m[1]}
+			if m[4] >= 0 {
+				v := s[from+m[4] : from+m[5]]
+				if v[0] == '"' || v[0] == '\'' {
+					v = v[1 : len(v)-1]
+				}
+				a.Value = html.UnescapeString(v)
+			}
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+// rewriteURLAttrs replaces the value of every href and src attribute in s
+// with rewrite(name, value), where name is lower case and value unescaped.
+// The new value is written back double quoted.
+func rewriteURLAttrs(s string, rewrite func(name, value string) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, a := range htmlAttrs(s) {
+		if a.Name != "href" && a.Name != "src" {
+			continue
+		}
+		sb.WriteString(s[last:a.Start])
+		fmt.Fprintf(&sb, `%s="%s"`, a.Name, html.EscapeString(rewrite(a.Name, a.Value)))
+		last = a.End
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
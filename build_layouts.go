@@ -0,0 +1,136 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The default layouts mirror the layouts/ directory a site can provide:
+// base.html wraps every page, partials/*.html are shared snippets and every
+// other file is the "main" block for one page type.
+//
+//go:embed assets/layouts
+var embeddedLayouts embed.FS
+
+// userLayoutsDir is where a site overrides layouts, relative to the site root.
+const userLayoutsDir = "layouts"
+
+// readLayoutSources returns every layout source keyed by its path inside
+// the layouts directory without the .html extension ("base", "list",
+// "partials/footer"). Files in dir replace the embedded default of the same
+// name and may add new layouts or partials.
+func readLayoutSources(dir string) (map[string]string, error) {
+	sources := make(map[string]string)
+	err := fs.WalkDir(embeddedLayouts, "assets/layouts", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".html" {
+			return err
+		}
+		data, err := fs.ReadFile(embeddedLayouts, p)
+		if err != nil {
+			return err
+		}
+		sources[layoutKey(strings.TrimPrefix(p, "assets/layouts/"))] = string(data)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded layouts: %w", err)
+	}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && os.IsNotExist(err) {
+				return nil // no overrides
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(p) != ".html" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sources[layoutKey(filepath.ToSlash(rel))] = string(data)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	return sources, nil
+}
+
+func layoutKey(rel string) string {
+	return strings.TrimSuffix(rel, ".html")
+}
+
+// parseLayouts builds one template per page layout. Each starts from base
+// plus all partials, then parses the layout file as its "main" block, so a
+// layout may also {{define}} its own "base" or partials.
+func parseLayouts(sources map[string]string, funcs template.FuncMap) (map[string]*template.Template, error) {
+	root := template.New("base").Funcs(funcs)
+	if _, err := root.Parse(sources["base"]); err != nil {
+		return nil, fmt.Errorf("layout base: %w", err)
+	}
+
+	var layoutNames []string
+	for _, key := range sortedSourceKeys(sources) {
+		switch {
+		case key == "base":
+		case strings.HasPrefix(key, "partials/"):
+			name := strings.TrimPrefix(key, "partials/")
+			if _, err := root.New(name).Parse(sources[key]); err != nil {
+				return nil, fmt.Errorf("partial %s: %w", name, err)
+			}
+		case !strings.Contains(key, "/"):
+			layoutNames = append(layoutNames, key)
+		}
+	}
+
+	layouts := make(map[string]*template.Template)
+	for _, name := range layoutNames {
+		t, err := root.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New("main").Parse(sources[name]); err != nil {
+			return nil, fmt.Errorf("layout %s: %w", name, err)
+		}
+		layouts[name] = t
+	}
+	return layouts, nil
+}
+
+func sortedSourceKeys(sources map[string]string) []string {
+	keys := make([]string, 0, len(sources))
+	for k := range sources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// layoutName picks the layout a page is rendered with: the layout named in
+// its front matter, otherwise the one for its page type.
+func (p *PageData) layoutName() string {
+	if p.FrontMatter.Layout != "" {
+		return strings.TrimSuffix(p.FrontMatter.Layout, ".html")
+	}
+	if p.kind != "" {
+		return p.kind
+	}
+	if p.FrontMatter.Type == "list" {
+		return "list"
+	}
+	return "normal"
+}
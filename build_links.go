@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	mdhtml "github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// renderMarkdown converts page's Markdown to HTML. Links and images are
+// resolved on the parsed document, so code blocks and inline code are
+// never touched.
+func renderMarkdown(cache *BuildCache, page *PageData) template.HTML {
+	return template.HTML(renderPage(cache, page, "", nil))
+}
+
+// renderPage renders page's Markdown, or only the section under the
+// heading with id section when it is set. embeddedIn lists the pages that
+// embed this one, see resolveWikilinks.
+func renderPage(cache *BuildCache, page *PageData, section string, embeddedIn []string) []byte {
+	mdParser := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs)
+	doc := markdown.Parse(page.MarkdownContent, mdParser)
+	if section != "" {
+		keepSection(doc, section)
+	}
+	resolveLinks(cache, page, doc)
+	resolveWikilinks(cache, page, doc, append(embeddedIn, page.RelPath))
+	transformCallouts(doc)
+
+	hooks := []mdhtml.RenderNodeFunc{renderImage, renderCallout, codeBlockRenderer(cache, page)}
+	opts := mdhtml.RendererOptions{
+		Flags:           mdhtml.CommonFlags,
+		HeadingIDPrefix: page.idPrefix,
+		RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+			for _, hook := range hooks {
+				if status, done := hook(w, node, entering); done {
+					return status, done
+				}
+			}
+			return ast.GoToNext, false
+		},
+	}
+	collectTOC(cache, page, doc, mdhtml.NewRenderer(opts))
+	return markdown.Render(doc, mdhtml.NewRenderer(opts))
+}
+
+// resolveLinks rewrites the destination of every link and image in doc,
+// including href and src attributes in raw HTML, to its site path.
+func resolveLinks(cache *BuildCache, page *PageData, doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			n.Destination = []byte(resolveLinkDest(cache, page, string(n.Destination)))
+		case *ast.Image:
+			n.Destination = []byte(resolveImageDest(cache, page, string(n.Destination)))
+		case *ast.HTMLSpan:
+			n.Literal = resolveHTMLAttrs(cache, page, n.Literal)
+		case *ast.HTMLBlock:
+			n.Literal = resolveHTMLAttrs(cache, page, n.Literal)
+		}
+		return ast.GoToNext
+	})
+}
+
+// resolveHTMLAttrs resolves href (as a link) and src (as an image)
+// attributes in a piece of raw HTML.
+func resolveHTMLAttrs(cache *BuildCache, page *PageData, raw []byte) []byte {
+	return []byte(rewriteURLAttrs(string(raw), func(name, dest string) string {
+		if name == "href" {
+			return resolveLinkDest(cache, page, dest)
+		}
+		return resolveImageDest(cache, page, dest)
+	}))
+}
+
+// isLocalDest reports whether dest points into the site: no scheme, no
+// host and not just a #fragment.
+func isLocalDest(dest string) bool {
+	if dest == "" || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "//") {
+		return false
+	}
+	u, err := url.Parse(dest)
+	return err == nil && u.Scheme == ""
+}
+
+// splitFragment splits "a.md#intro" into "a.md" and "#intro", keeping any
+// query string with the suffix.
+func splitFragment(dest string) (string, string) {
+	if i := strings.IndexAny(dest, "?#"); i >= 0 {
+		return dest[:i], dest[i:]
+	}
+	return dest, ""
+}
+
+// resolveLinkDest turns a link to a local .md file into the site path of
+// that page, keeping any #fragment. The file is looked up relative to the
+// linking page first ("second.md", "./second.md", "../index.md"), then
+// relative to the site root ("blog/second.md", "/blog/second.md").
+// Anything else is returned unchanged.
+func resolveLinkDest(cache *BuildCache, page *PageData, dest string) string {
+	if !isLocalDest(dest) {
+		return dest
+	}
+	target, suffix := splitFragment(dest)
+	if !strings.HasSuffix(strings.ToLower(target), ".md") {
+		return dest
+	}
+	target, _ = url.PathUnescape(target)
+
+	for _, candidate := range linkCandidates(page, target) {
+		page.linkTargets = append(page.linkTargets, candidate)
+		for _, other := range cache.Pages {
+			if other.RelPath == candidate {
+				return sitePath(pagePath(cache, other)) + suffix
+			}
+		}
+	}
+	page.addProblem(dest, "unresolved link %q", dest)
+	return dest
+}
+
+// linkCandidates lists the source paths a local link may refer to,
+// most specific first.
+func linkCandidates(page *PageData, target string) []string {
+	if strings.HasPrefix(target, "/") {
+		return []string{path.Clean(strings.TrimPrefix(target, "/"))}
+	}
+	fromPage := path.Join(path.Dir(page.RelPath), target)
+	fromRoot := path.Clean(target)
+	if fromPage == fromRoot || strings.HasPrefix(fromRoot, "../") {
+		return []string{fromPage}
+	}
+	return []string{fromPage, fromRoot}
+}
+
+// resolveImageDest turns a local image path into its site path. Relative
+// paths are resolved against the page's source directory, falling back to
+// the site root, so "../images/a.png" and "images/a.png" both work from
+// blog/post.md. Paths that already carry the base path are left alone.
+func resolveImageDest(cache *BuildCache, page *PageData, dest string) string {
+	if !isLocalDest(dest) {
+		return dest
+	}
+	target, suffix := splitFragment(dest)
+	target, _ = url.PathUnescape(target)
+	if strings.HasPrefix(target, "/") {
+		resolved := dest
+		basePath := strings.TrimSuffix(cache.Config.Website.BasePath, "/")
+		if basePath != "" && (target == basePath || strings.HasPrefix(target, basePath+"/")) {
+			target = strings.TrimPrefix(target, basePath)
+		} else {
+			resolved = sitePath(target) + suffix
+		}
+		if !publishFile(cache, strings.TrimPrefix(target, "/")) {
+			page.addProblem(dest, "missing image %q", dest)
+		}
+		return resolved
+	}
+
+	// Images next to the page are copied with it; see copyAttachments. An
+	// image is missing when its file won't be in the output, not only when
+	// there is no such file.
+	fromPage := path.Join(path.Dir(page.RelPath), target)
+	if !publishFile(cache, fromPage) {
+		if fromRoot := path.Clean(target); publishFile(cache, fromRoot) {
+			fromPage = fromRoot
+		} else {
+			page.addProblem(dest, "missing image %q", dest)
+		}
+	}
+	return sitePath("/"+fromPage) + suffix
+}
+
+func fileExists(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && !info.IsDir()
+}
+
+// addProblem records a broken link or image for reportProblems, pointing
+// at the first line of the page's Markdown that contains dest.
+func (p *PageData) addProblem(dest, format string, args ...any) {
+	line := 0
+	if i := bytes.Index(p.MarkdownContent, []byte(dest)); i >= 0 {
+		line = p.bodyLine + bytes.Count(p.MarkdownContent[:i], []byte("\n"))
+	}
+	p.problems = append(p.problems, &sourceError{File: p.RelPath, Line: line, Err: fmt.Errorf(format, args...)})
+}
+
+// renderImage writes Markdown images with the site's image styling.
+func renderImage(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	img, ok := node.(*ast.Image)
+	if !ok {
+		return ast.GoToNext, false
+	}
+	if !entering {
+		return ast.GoToNext, true
+	}
+	title := ""
+	if len(img.Title) > 0 {
+		title = fmt.Sprintf(` title="%s"`, html.EscapeString(string(img.Title)))
+	}
+	fmt.Fprintf(w,
+		`<img src="%s" alt="%s"%s style="max-width:800px;width:100%%;height:auto;" class="mb-3 img-fluid border border-1 border-dark"/>`,
+		html.EscapeString(string(img.Destination)), html.EscapeString(altText(img)), title)
+	return ast.SkipChildren, true
+}
+
+// altText is the plain text of an image's description.
+func altText(node ast.Node) string {
+	var b strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); entering && leaf != nil {
+			b.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return b.String()
+}
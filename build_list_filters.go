@@ -0,0 +1,141 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Accepted values of the list front matter keys tagMatch (default any),
+// sortBy (default date) and sortOrder (default depends on sortBy).
+var (
+	tagMatches = []string{"any", "all"}
+	sortKeys   = []string{"date", "title", "weight", "updated"}
+	sortOrders = []string{"asc", "desc"}
+)
+
+// listIncludes reports whether list page listing shows page p. All filters
+// that are set must match: directory, authorFilter, tagFilter (any or all
+// tags), excludeTags and the since/until date range.
+func listIncludes(listing, p *PageData) bool {
+	fm := listing.FrontMatter
+
+	// Lists without author or tag filters show their own directory, filtered
+	// lists the whole site unless directory is set.
+	if fm.Directory != "" || (len(fm.AuthorFilter) == 0 && len(fm.TagFilter) == 0) {
+		if !inListDirectory(listing, p) {
+			return false
+		}
+	}
+
+	if len(fm.AuthorFilter) > 0 && !containsFold(fm.AuthorFilter, p.FrontMatter.Author) {
+		return false
+	}
+
+	if len(fm.TagFilter) > 0 {
+		matched := 0
+		for _, tag := range fm.TagFilter {
+			if containsFold(p.FrontMatter.Tags, tag) {
+				matched++
+			}
+		}
+		if matched == 0 || (fm.TagMatch == "all" && matched < len(fm.TagFilter)) {
+			return false
+		}
+	}
+
+	for _, tag := range fm.ExcludeTags {
+		if containsFold(p.FrontMatter.Tags, tag) {
+			return false
+		}
+	}
+
+	date := p.FrontMatter.ParsedDate
+	if !fm.ParsedSince.IsZero() && date.Before(fm.ParsedSince) {
+		return false
+	}
+	if !fm.ParsedUntil.IsZero() && !date.Before(untilEnd(fm.Until, fm.ParsedUntil)) {
+		return false
+	}
+	return true
+}
+
+// inListDirectory reports whether p is in the directory listing shows: the
+// directory front matter (relative to the site root), else the listing
+// page's own directory. With recursive, subdirectories count too.
+func inListDirectory(listing, p *PageData) bool {
+	dir := filepath.Dir(listing.RelPath)
+	if d := strings.Trim(listing.FrontMatter.Directory, "/"); d != "" {
+		dir = filepath.FromSlash(d)
+	}
+	if dir == "." {
+		dir = ""
+	}
+	pageDir := filepath.Dir(p.RelPath)
+	if pageDir == "." {
+		pageDir = ""
+	}
+	if pageDir == dir {
+		return true
+	}
+	return listing.FrontMatter.Recursive && (dir == "" || strings.HasPrefix(pageDir, dir+string(filepath.Separator)))
+}
+
+// untilEnd is the first instant after an until date. A date without a
+// time, "2025-01-31", includes that whole day.
+func untilEnd(value string, until time.Time) time.Time {
+	if _, err := time.Parse("2006-01-02", strings.TrimSpace(value)); err == nil {
+		return until.AddDate(0, 0, 1)
+	}
+	return until.Add(time.Nanosecond)
+}
+
+// containsFold reports whether names holds name, ignoring case and
+// surrounding space.
+func containsFold(names []string, name string) bool {
+	name = strings.TrimSpace(name)
+	for _, n := range names {
+		if strings.EqualFold(strings.TrimSpace(n), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortListedPages orders pages by sortBy and sortOrder. Dates and update
+// times default to newest first, titles and weights to ascending; pages
+// that compare equal stay newest first.
+func sortListedPages(pages []*PageData, sortBy, sortOrder string) {
+	byDate := func(a, b *PageData) int { return b.FrontMatter.ParsedDate.Compare(a.FrontMatter.ParsedDate) }
+	slices.SortStableFunc(pages, byDate)
+
+	var compare func(a, b *PageData) int // ascending
+	switch sortBy {
+	case "title":
+		compare = func(a, b *PageData) int {
+			return strings.Compare(strings.ToLower(a.FrontMatter.Title), strings.ToLower(b.FrontMatter.Title))
+		}
+	case "weight":
+		compare = func(a, b *PageData) int { return a.FrontMatter.Weight - b.FrontMatter.Weight }
+	case "updated":
+		compare = func(a, b *PageData) int { return lastModified(a).Compare(lastModified(b)) }
+	default:
+		compare = func(a, b *PageData) int { return a.FrontMatter.ParsedDate.Compare(b.FrontMatter.ParsedDate) }
+	}
+	descending := sortOrder == "desc" || (sortOrder == "" && (sortBy == "" || sortBy == "date" || sortBy == "updated"))
+	slices.SortStableFunc(pages, func(a, b *PageData) int {
+		if descending {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+}
+
+// lastModified is the updated date of p, else its date.
+func lastModified(p *PageData) time.Time {
+	if !p.FrontMatter.ParsedUpdated.IsZero() {
+		return p.FrontMatter.ParsedUpdated
+	}
+	return p.FrontMatter.ParsedDate
+}
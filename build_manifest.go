@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// manifestFileName is written into the output directory after every build.
+// Deleting the output directory (krems --clean) therefore forces a full build.
+const manifestFileName = ".krems-manifest.json"
+
+// manifestVersion is bumped whenever the manifest format or the meaning of
+// its hashes changes, so older manifests trigger a full rebuild.
+const manifestVersion = 1
+
+// buildManifest records what each source rendered to, so the next build can
+// skip pages whose inputs are unchanged and delete outputs of removed sources.
+type buildManifest struct {
+	Version  int                       `json:"version"`
+	SiteHash string                    `json:"siteHash"` // config + templates; any change forces a full build
+	Entries  map[string]*manifestEntry `json:"entries"`  // keyed by source path
+
+	mu       sync.Mutex
+	rendered int
+	skipped  int
+}
+
+// manifestEntry is one rendered source: a Markdown file or a generated
+// tag/author page.
+type manifestEntry struct {
+	Source          string   `json:"source"`
+	ContentHash     string   `json:"contentHash"`
+	FrontMatterHash string   `json:"frontMatterHash"`
+	DepsHash        string   `json:"depsHash"` // output paths / front matter of the pages it links to or lists
+	Outputs         []string `json:"outputs"`  // relative to the output directory, slash separated
+}
+
+func newBuildManifest(siteHash string) *buildManifest {
+	return &buildManifest{
+		Version:  manifestVersion,
+		SiteHash: siteHash,
+		Entries:  make(map[string]*manifestEntry),
+	}
+}
+
+// loadManifest reads the manifest of the previous build from outputDir.
+// A missing or unreadable manifest returns nil, which means a full build.
+func loadManifest(outputDir string) *buildManifest {
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFileName))
+	if err != nil {
+		return nil
+	}
+	var m buildManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		fmt.Printf("Warning: ignoring unreadable build manifest: %v\n", err)
+		return nil
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]*manifestEntry)
+	}
+	return &m
+}
+
+func (m *buildManifest) save(outputDir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, manifestFileName), data, 0644)
+}
+
+// record stores the entry for a source rendered (or skipped) in this build.
+func (m *buildManifest) record(e *manifestEntry, rendered bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[e.Source] = e
+	if rendered {
+		m.rendered++
+	} else {
+		m.skipped++
+	}
+}
+
+// recordGenerated stores outputs that are rewritten on every build, such as
+// feeds, so removeStaleOutputs knows about them. They are not counted as
+// rendered pages. outputs must list everything source writes in this
+// build: whatever the previous build recorded under source and this one
+// does not, such as the redirect stub of a removed alias, is deleted.
+func (m *buildManifest) recordGenerated(source string, outputs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[source] = &manifestEntry{Source: source, Outputs: outputs}
+}
+
+// upToDate reports whether e matches the previous build and all of its
+// outputs are still on disk.
+func (m *buildManifest) upToDate(e *manifestEntry, outputDir string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	old, ok := m.Entries[e.Source]
+	m.mu.Unlock()
+	if !ok || old.ContentHash != e.ContentHash || old.FrontMatterHash != e.FrontMatterHash || old.DepsHash != e.DepsHash {
+		return false
+	}
+	for _, out := range old.Outputs {
+		if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(out))); err != nil {
+			return false
+		}
+	}
+	e.Outputs = old.Outputs
+	return true
+}
+
+// removeStaleOutputs deletes files the previous build produced that this
+// build did not, then prunes directories left empty.
+func removeStaleOutputs(previous, next *buildManifest, outputDir string) {
+	if previous == nil {
+		return
+	}
+	current := make(map[string]bool)
+	for _, e := range next.Entries {
+		for _, out := range e.Outputs {
+			current[out] = true
+		}
+	}
+	for _, e := range previous.Entries {
+		for _, out := range e.Outputs {
+			// Never delete outside outputDir, whatever the manifest says.
+			if current[out] || !inSite(path.Clean(out)) {
+				continue
+			}
+			stale := filepath.Join(outputDir, filepath.FromSlash(out))
+			if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("Warning: failed to remove stale output %s: %v\n", stale, err)
+				continue
+			}
+			fmt.Printf("Removed stale: %s\n", stale)
+			pruneEmptyDirs(filepath.Dir(stale), outputDir)
+		}
+	}
+}
+
+// pruneEmptyDirs removes dir and its parents while they are empty,
+// stopping at root.
+func pruneEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return // not empty (or already gone)
+		}
+	}
+}
+
+// relOutput returns path relative to outputDir with forward slashes,
+// as stored in the manifest.
+func relOutput(outputDir, path string) string {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// siteHash covers every input that affects all pages at once: the effective
+// configuration, the layouts and the alternative CSS/JS file lists.
+func siteHash(cfg *Config, layoutSources map[string]string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00", manifestVersion)
+	cfgJSON, _ := json.Marshal(cfg)
+	h.Write(cfgJSON)
+	for _, key := range sortedSourceKeys(layoutSources) {
+		fmt.Fprintf(h, "\x00%s\x00%s", key, layoutSources[key])
+	}
+	for _, dir := range []string{cfg.Website.AlternativeCSSDir, cfg.Website.AlternativeJSDir} {
+		if dir == "" {
+			continue
+		}
+		files, _ := os.ReadDir(dir)
+		for _, f := range files {
+			fmt.Fprintf(h, "\x00%s/%s", dir, f.Name())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pageDepsHash hashes what a page's HTML depends on besides its own source:
+// the menu targets, and either the pages it lists (list pages) or the pages
+// it links to (normal pages).
+func pageDepsHash(cache *BuildCache, page *PageData) string {
+	h := sha256.New()
+	// The banner of a scheduled or expired page changes with the clock alone.
+	fmt.Fprintf(h, "status\x00%s\n", page.Status)
+	for _, item := range cache.Config.Menu {
+		fmt.Fprintf(h, "menu\x00%s\x00%s\n", item.Path, FindPageByRelPath(cache, item.Path))
+	}
+	if page.FrontMatter.Type == "list" {
+		if page.Pager != nil {
+			fmt.Fprintf(h, "pager\x00%d\x00%d\n", page.Pager.Number, page.Pager.Total)
+		}
+		for _, p := range collectListedPages(cache, page) {
+			// ContentHash: excerpts and reading times show the body.
+			fmt.Fprintf(h, "list\x00%s\x00%s\x00%s\x00%s\n", p.RelPath, p.OutputDir, p.FrontMatterHash, p.ContentHash)
+		}
+	} else {
+		for _, rel := range page.embeds {
+			if p := findPage(cache, rel); p != nil {
+				fmt.Fprintf(h, "embed\x00%s\x00%s\n", rel, p.ContentHash)
+			}
+		}
+		if page.wikilinks {
+			fmt.Fprintf(h, "pages\x00%s\n", pageSetHash(cache))
+		}
+		targets := append([]string(nil), page.linkTargets...)
+		sort.Strings(targets)
+		for _, t := range targets {
+			fmt.Fprintf(h, "link\x00%s\x00%s\n", t, FindPageByRelPath(cache, t))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newPageManifestEntry describes page's current inputs for the manifest.
+func newPageManifestEntry(cache *BuildCache, page *PageData) *manifestEntry {
+	return &manifestEntry{
+		Source:          page.RelPath,
+		ContentHash:     page.ContentHash,
+		FrontMatterHash: page.FrontMatterHash,
+		DepsHash:        pageDepsHash(cache, page),
+	}
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
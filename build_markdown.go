@@ -2,9 +2,10 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,7 +16,8 @@ import (
 )
 
 // parse markdown => PageData
-func parseMarkdownFiles(root string) ([]*PageData, error) {
+// Front matter dates without a zone are read in loc.
+func parseMarkdownFiles(root string, loc *time.Location) ([]*PageData, error) {
 	var pages []*PageData
 	ignoredDirs := map[string]bool{
 		".tmp":    true, // Changed from "tmp" to ".tmp"
@@ -64,8 +66,12 @@ func parseMarkdownFiles(root string) ([]*PageData, error) {
 		if err != nil {
 			return err
 		}
-		page, err := parseFrontMatter(raw)
+		page, err := parseFrontMatter(filepath.ToSlash(rel), raw, loc)
 		if err != nil {
+			var srcErr *sourceError
+			if errors.As(err, &srcErr) {
+				return err // already names the file and line
+			}
 			return fmt.Errorf("error parsing front matter in %s: %w", p, err)
 		}
 		page.RelPath = filepath.ToSlash(rel)
@@ -75,121 +81,125 @@ func parseMarkdownFiles(root string) ([]*PageData, error) {
 	return pages, err
 }
 
-func parseFrontMatter(fileBytes []byte) (*PageData, error) {
+// sourceError points at a line of a source file, printed as "file:line: msg".
+type sourceError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *sourceError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.Err
+}
+
+// parseFrontMatter splits a Markdown file into front matter and body.
+// relPath is only used in error messages.
+func parseFrontMatter(relPath string, fileBytes []byte, loc *time.Location) (*PageData, error) {
 	page := &PageData{}
 	delim := []byte("---")
 	parts := bytes.SplitN(fileBytes, delim, 3)
 	if len(parts) == 3 {
 		fmBytes := bytes.TrimSpace(parts[1])
+		// Decode from the untrimmed text so node lines, offset by the lines
+		// before it, are file lines.
+		var node yaml.Node
+		if err := yaml.Unmarshal(parts[1], &node); err != nil {
+			return nil, err
+		}
 		var fm PageFrontMatter
-		if err := yaml.Unmarshal(fmBytes, &fm); err != nil {
+		if err := node.Decode(&fm); err != nil {
 			return nil, err
 		}
 		if fm.Type == "" {
 			fm.Type = "normal"
 		}
-		if fm.Date != "" {
-			if t, err := time.Parse("2006-01-02", fm.Date); err == nil {
-				fm.ParsedDate = t
+		lineOffset := bytes.Count(parts[0], []byte("\n"))
+		page.frontMatterLines = frontMatterLines(&node, lineOffset)
+		dates := []struct {
+			key    string
+			value  string
+			parsed *time.Time
+		}{
+			{"date", fm.Date, &fm.ParsedDate},
+			{"created", fm.Created, &fm.ParsedCreated},
+			{"updated", fm.Updated, &fm.ParsedUpdated},
+			{"publishDate", fm.PublishDate, &fm.ParsedPublishDate},
+			{"expiryDate", fm.ExpiryDate, &fm.ParsedExpiryDate},
+			{"since", fm.Since, &fm.ParsedSince},
+			{"until", fm.Until, &fm.ParsedUntil},
+		}
+		for _, d := range dates {
+			if d.value == "" {
+				continue
+			}
+			t, err := parseDate(d.value, loc)
+			if err != nil {
+				return nil, &sourceError{
+					File: relPath,
+					Line: page.frontMatterLines[d.key],
+					Err:  fmt.Errorf("%s: %w", d.key, err),
+				}
+			}
+			*d.parsed = t
+		}
+		choices := []struct {
+			key     string
+			value   string
+			allowed []string
+		}{
+			{"listStyle", fm.ListStyle, listStyles},
+			{"tagMatch", fm.TagMatch, tagMatches},
+			{"sortBy", fm.SortBy, sortKeys},
+			{"sortOrder", fm.SortOrder, sortOrders},
+		}
+		for _, c := range choices {
+			if c.value != "" && !slices.Contains(c.allowed, c.value) {
+				return nil, &sourceError{
+					File: relPath,
+					Line: page.frontMatterLines[c.key],
+					Err:  fmt.Errorf("%s: unknown value %q (use %s)", c.key, c.value, strings.Join(c.allowed, ", ")),
+				}
+			}
+		}
+		// The slug names the page's directory; it must not reach outside it.
+		if s := strings.Trim(fm.Slug, "/ "); s == "." || strings.Contains(s, "..") || strings.ContainsAny(s, `/\`) {
+			return nil, &sourceError{
+				File: relPath,
+				Line: page.frontMatterLines["slug"],
+				Err:  fmt.Errorf("slug: %q must be a single URL segment, without / or ..", fm.Slug),
 			}
 		}
 		page.FrontMatter = fm
 		page.MarkdownContent = bytes.TrimSpace(parts[2])
+		bodyStart := len(fileBytes) - len(bytes.TrimLeft(parts[2], " \t\r\n"))
+		page.bodyLine = 1 + bytes.Count(fileBytes[:bodyStart], []byte("\n"))
+		page.FrontMatterHash = hashBytes(fmBytes)
 	} else {
 		page.FrontMatter = PageFrontMatter{Type: "normal"}
 		page.MarkdownContent = fileBytes
+		page.bodyLine = 1
 	}
+	page.ContentHash = hashBytes(page.MarkdownContent)
 	return page, nil
 }
 
-// In build.go, modify the fixLinksAndImages function to better handle "../" relative paths
-func fixLinksAndImages(cache *BuildCache, page *PageData) []byte {
-	lines := bytes.Split(page.MarkdownContent, []byte("\n"))
-	reLink := regexp.MustCompile(`\[(.*?)\]\((.*?)\)`)
-	reImg := regexp.MustCompile(`!\[(.*?)\]\((.*?)\)`)
-
-	for i, line := range lines {
-			// images => 400px
-			line = reImg.ReplaceAllFunc(line, func(m []byte) []byte {
-					sub := reImg.FindSubmatch(m)
-					if len(sub) < 3 {
-							return m
-					}
-					alt := string(sub[1])
-					imgPath := string(sub[2])
-					return []byte(fmt.Sprintf(
-							`<img src="%s" alt="%s" style="max-width:800px;width:100%%;height:auto;" class="mb-3 img-fluid border border-1 border-dark"/>`,
-							imgPath, alt))
-			})
-
-			// local .md => /slug/
-			line = reLink.ReplaceAllFunc(line, func(m []byte) []byte {
-					sub := reLink.FindSubmatch(m)
-					if len(sub) < 3 {
-							return m
-					}
-					linkText := string(sub[1])
-					linkTarget := string(sub[2])
-					lc := strings.ToLower(linkTarget)
-
-					if strings.HasPrefix(lc, "http://") || strings.HasPrefix(lc, "https://") {
-							return m // external => no rewrite
-					}
-					
-					if strings.HasSuffix(lc, ".md") {
-							// Handle relative paths starting with ../
-							linkCandidate := linkTarget
-							if strings.HasPrefix(linkCandidate, "../") {
-									// Get the current page's directory path
-									dirOfPage := filepath.Dir(page.RelPath)
-									if dirOfPage == "." {
-											dirOfPage = ""
-									}
-									
-									// Resolve the relative path properly
-									resolvedPath := filepath.Clean(filepath.Join(dirOfPage, linkCandidate))
-									linkCandidate = filepath.ToSlash(resolvedPath)
-							} else if !strings.Contains(linkCandidate, "/") {
-									// If no slash, assume same dir => join with page's dir
-									dirOfPage := filepath.Dir(page.RelPath)
-									if dirOfPage == "." {
-											dirOfPage = ""
-									}
-									linkCandidate = filepath.Join(dirOfPage, linkCandidate)
-							}
-							
-							linkCandidate = filepath.ToSlash(linkCandidate)
-
-							// find a matching .RelPath
-							for _, other := range cache.Pages {
-									if other.RelPath == linkCandidate {
-											outDir := FindPageByRelPath(cache, other.RelPath)
-											// Prepend BasePath, ensuring no double slashes if BasePath is "/" or empty
-											// and outDir starts with "/"
-											// A common pattern: ensure BasePath is "" or "/path" (no trailing slash)
-											// and outDir is "slug" (no leading slash for joining)
-											// For now, assume outDir is like "slug" and BasePath is "/base" or ""
-											// So, basePath + "/" + outDir, but handle empty basePath and ensure one slash.
-											// Let's assume cache.Config.Website.BasePath is available.
-											// And outDir is like "actual-slug" (no leading/trailing slashes from FindPageByRelPath)
-											// Then link should be {{basePath}}/{{outDir}}/
-											// The current Fprintf gives /slug/, so we need to adjust.
-											// Let's assume FindPageByRelPath returns "slug" (no slashes)
-											// and BasePath is "/base" or ""
-
-											// The existing fmt.Sprintf gives "/%s/", so outDir is just the slug.
-											// We want {{BasePath}}/{{slug}}/
-											// We want the final path to be like /basePath/slug/
-											// sitePath expects a path like "/slug/"
-											targetPathForSitePath := "/" + outDir + "/"
-											return []byte(fmt.Sprintf("[%s](%s)", linkText, sitePath(targetPathForSitePath)))
-									}
-							}
-					}
-					return m
-			})
-
-			lines[i] = line
+// frontMatterLines maps each top-level front matter key to the file line
+// of its value; offset is the number of lines before the front matter.
+func frontMatterLines(doc *yaml.Node, offset int) map[string]int {
+	lines := make(map[string]int)
+	if len(doc.Content) == 0 {
+		return lines
+	}
+	m := doc.Content[0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		lines[m.Content[i].Value] = offset + m.Content[i+1].Line
 	}
-	return bytes.Join(lines, []byte("\n"))
+	return lines
 }
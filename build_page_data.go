@@ -8,16 +8,45 @@ import (
 
 // PageFrontMatter is the front matter in each .md file
 type PageFrontMatter struct {
-	Title        string `yaml:"title"`
-	Type         string `yaml:"type"` // normal|list
-	Description  string `yaml:"description"`
-	Image        string `yaml:"image"` // "/images/foo.png" or "images/foo.png"
-	Date         string `yaml:"date"`
-	ParsedDate   time.Time
-	Author       string   `yaml:"author"`
-	Tags         []string `yaml:"tags"`
-	TagFilter    []string `yaml:"tagFilter"`
-	AuthorFilter []string `yaml:"authorFilter"`
+	Title             string `yaml:"title"`
+	Slug              string `yaml:"slug"`   // URL name instead of the slug of the title
+	Type              string `yaml:"type"`   // normal|list
+	Layout            string `yaml:"layout"` // name of a layout in layouts/, e.g. "landing"
+	Description       string `yaml:"description"`
+	Image             string `yaml:"image"` // "/images/foo.png" or "images/foo.png"
+	Date              string `yaml:"date"`
+	ParsedDate        time.Time
+	Created           string `yaml:"created"`
+	ParsedCreated     time.Time
+	Updated           string `yaml:"updated"`
+	ParsedUpdated     time.Time
+	Draft             bool   `yaml:"draft"`
+	PublishDate       string `yaml:"publishDate"` // hidden before this date
+	ParsedPublishDate time.Time
+	ExpiryDate        string `yaml:"expiryDate"` // hidden from this date on
+	ParsedExpiryDate  time.Time
+	Author            string   `yaml:"author"`
+	Tags              []string `yaml:"tags"`
+	Aliases           []string `yaml:"aliases"` // old site paths that redirect here, e.g. "/old-title/"
+	Weight            int      `yaml:"weight"`  // position in lists with sortBy: weight
+	TagFilter         []string `yaml:"tagFilter"`
+	TagMatch          string   `yaml:"tagMatch"` // any|all of tagFilter
+	ExcludeTags       []string `yaml:"excludeTags"`
+	AuthorFilter      []string `yaml:"authorFilter"`
+	Directory         string   `yaml:"directory"` // directory to list instead of the list page's own
+	Recursive         bool     `yaml:"recursive"` // also list subdirectories
+	Since             string   `yaml:"since"`
+	ParsedSince       time.Time
+	Until             string `yaml:"until"`
+	ParsedUntil       time.Time
+	SortBy            string `yaml:"sortBy"`      // date|title|weight|updated
+	SortOrder         string `yaml:"sortOrder"`   // asc|desc
+	Limit             int    `yaml:"limit"`       // most pages listed, before pagination
+	Paginate          *int   `yaml:"paginate"`    // listed pages per page; 0 turns off the site's paginate
+	ListStyle         string `yaml:"listStyle"`   // list|cards|compact, see listStyles
+	TOC               *bool  `yaml:"toc"`         // show a table of contents; unset uses the site's toc.enabled
+	TOCMinLevel       int    `yaml:"tocMinLevel"` // highest heading level listed, e.g. 2 for ##
+	TOCMaxLevel       int    `yaml:"tocMaxLevel"` // lowest heading level listed
 }
 
 // PageData captures info for one .md file => HTML page
@@ -28,12 +57,55 @@ type PageData struct {
 	RelPath         string // e.g. "tech/Building_Quacker.md"
 	OutputDir       string // e.g. "tmp/tech/building_quacker"
 	IsIndex         bool
+	ContentHash     string // hash of the Markdown body, for incremental builds
+	FrontMatterHash string // hash of the raw front matter, for incremental builds
+	Status          string // "draft", "scheduled" or "expired" for unpublished pages, see filterUnpublished
+	Pager           *Pager // set on list pages split by paginate, see paginateListPages
+
+	// TOC is the heading tree for the table of contents, set while the
+	// Markdown is rendered.
+	TOC []*TOCEntry
+
+	// kind is set on generated pages ("tag", "author", "404") to pick
+	// their layout.
+	kind string
+
+	// embeds lists the notes embedded with ![[Note]], and wikilinks is set
+	// when the page has any [[wikilinks]]; both feed pageDepsHash.
+	embeds    []string
+	wikilinks bool
+
+	// idPrefix goes before the heading ids of a note rendered into another
+	// page, so they don't clash with the ids of that page.
+	idPrefix string
+
+	// tocInline is set when a [[toc]] marker placed the table of contents
+	// in the content.
+	tocInline bool
+
+	// linkTargets holds every local .md path this page links to, resolved or
+	// not, so the page is re-rendered when one of them appears or moves.
+	linkTargets []string
+
+	// Source positions for build warnings: the file line of each front
+	// matter key and the line where MarkdownContent starts.
+	frontMatterLines map[string]int
+	bodyLine         int
+
+	// problems are the unresolved links and missing images found while
+	// rendering, reported by reportProblems.
+	problems []*sourceError
 }
 
 type BuildCache struct {
 	Pages                 []*PageData
 	Config                *Config
-	CurrentBuildOutputDir string // Stores the actual output directory for the current build (e.g., "tmp" or a temp path)
+	CurrentBuildOutputDir string         // Stores the actual output directory for the current build (e.g., "tmp" or a temp path)
+	Manifest              *buildManifest // outputs of the previous build, nil for a full build
+	NextManifest          *buildManifest // outputs of this build, saved when it completes
+	Template              *siteTemplate  // parsed once per build, shared by all render workers
+
+	vault vault // site files for wikilinks, see siteVault
 }
 
 // Global var so listpages.go can see it
@@ -43,6 +115,16 @@ func assignGlobalCache(cache *BuildCache) {
 	globalBuildCache = cache
 }
 
+// pagePath returns the site path of a page, e.g. "/tech/building-quacker/"
+// or "/" for the root index. Pass it through sitePath or absoluteURL.
+func pagePath(cache *BuildCache, p *PageData) string {
+	rel := relOutput(cache.CurrentBuildOutputDir, p.OutputDir)
+	if rel == "." || rel == "" {
+		return "/"
+	}
+	return "/" + rel + "/"
+}
+
 // find .md => "outputDir/dir/slug" minus "outputDir/" => "dir/slug"
 func FindPageByRelPath(cache *BuildCache, relPath string) string {
 	for _, p := range cache.Pages {
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Pager is set on list pages that paginate splits into several pages.
+// Page 1 is the list page itself; the others are copies of it written to
+// <list>/page/2/, <list>/page/3/ and so on.
+type Pager struct {
+	Number int         // 1-based
+	Total  int         // number of pages in the list
+	Pages  []*PageData // the listed pages shown on this page
+	Prev   *PageData   // nil on the first page
+	Next   *PageData   // nil on the last page
+
+	all []*PageData // every page of the list, page 1 first
+}
+
+// First is page 1 of the list.
+func (pg *Pager) First() *PageData {
+	return pg.all[0]
+}
+
+// isExtraPage reports whether p is page 2 or later of a paginated list.
+// Such pages exist only as output; they are not in cache.Pages.
+func (p *PageData) isExtraPage() bool {
+	return p.Pager != nil && p.Pager.Number > 1
+}
+
+// pageSize is the number of listed pages per page for list page p:
+// its paginate front matter, else the site's paginate. 0 means no
+// pagination.
+func pageSize(cfg *Config, p *PageData) int {
+	if p.FrontMatter.Paginate != nil {
+		return *p.FrontMatter.Paginate
+	}
+	return cfg.Paginate
+}
+
+// paginateListPages splits every list page with more listed pages than its
+// page size. It runs once all pages, including tag and author pages, are
+// registered and their output directories are known.
+func paginateListPages(cache *BuildCache) {
+	for _, p := range cache.Pages {
+		if p.FrontMatter.Type != "list" {
+			continue
+		}
+		size := pageSize(cache.Config, p)
+		if size <= 0 {
+			continue
+		}
+		items := collectListedPages(cache, p)
+		if len(items) <= size {
+			continue
+		}
+
+		total := (len(items) + size - 1) / size
+		all := make([]*PageData, total)
+		all[0] = p
+		for n := 2; n <= total; n++ {
+			extra := *p
+			// Named after the list page, not its directory: blog/index.md and
+			// blog/archive.md may both have a page 2.
+			extra.RelPath = strings.TrimSuffix(p.RelPath, ".md") + "/page/" + strconv.Itoa(n) + ".md"
+			extra.OutputDir = filepath.Join(p.OutputDir, "page", strconv.Itoa(n))
+			all[n-1] = &extra
+		}
+		for i, page := range all {
+			end := min((i+1)*size, len(items))
+			page.Pager = &Pager{
+				Number: i + 1,
+				Total:  total,
+				Pages:  items[i*size : end],
+				all:    all,
+			}
+		}
+		for i, page := range all {
+			if i > 0 {
+				page.Pager.Prev = all[i-1]
+			}
+			if i+1 < total {
+				page.Pager.Next = all[i+1]
+			}
+		}
+	}
+}
+
+// allPages returns cache.Pages followed by the extra pages of paginated
+// lists: everything that is rendered.
+func allPages(cache *BuildCache) []*PageData {
+	pages := append([]*PageData(nil), cache.Pages...)
+	for _, p := range cache.Pages {
+		if p.Pager != nil {
+			pages = append(pages, p.Pager.all[1:]...)
+		}
+	}
+	return pages
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// defaultJobs is the worker count used when --jobs is not given.
+func defaultJobs() int {
+	return runtime.NumCPU()
+}
+
+// forEachPage runs fn for every page on a pool of at most jobs workers.
+// Every page is attempted; errors are returned together, in page order,
+// so the report is the same regardless of scheduling.
+func forEachPage(pages []*PageData, jobs int, fn func(p *PageData) error) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	errs := make([]error, len(pages))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs && w < len(pages); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(pages[i]); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", pages[i].RelPath, err)
+				}
+			}
+		}()
+	}
+	for i := range pages {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+var rePermalinkToken = regexp.MustCompile(`:([a-z]+)`)
+
+// pageSlug is the last part of a page's default URL: the slug front matter,
+// else the slug of its title, else its file name.
+func pageSlug(p *PageData) string {
+	if s := strings.Trim(p.FrontMatter.Slug, "/ "); s != "" {
+		return s
+	}
+	if s := slug.Make(p.FrontMatter.Title); s != "" {
+		return s
+	}
+	return strings.TrimSuffix(path.Base(p.RelPath), ".md")
+}
+
+// permalinkPattern returns the pattern from config.yaml's permalinks for
+// the closest directory of p that has one, or "" for the default
+// <directory>/<slug>/. The key "/" applies to the whole site.
+func permalinkPattern(cfg *Config, p *PageData) string {
+	patterns := make(map[string]string, len(cfg.Permalinks))
+	for dir, pattern := range cfg.Permalinks {
+		patterns[strings.Trim(dir, "/")] = pattern // "blog", "/blog/" and "blog/" are the same
+	}
+	for dir := path.Dir(p.RelPath); ; dir = path.Dir(dir) {
+		key := dir
+		if key == "." {
+			key = ""
+		}
+		if pattern, ok := patterns[key]; ok {
+			return pattern
+		}
+		if dir == "." {
+			return ""
+		}
+	}
+}
+
+// expandPermalink fills in a pattern such as "/:year/:month/:slug/" for p
+// and returns the page's directory relative to the site root.
+func expandPermalink(pattern string, p *PageData) (string, error) {
+	var err error
+	expanded := rePermalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		date := p.FrontMatter.ParsedDate
+		needsDate := token == ":year" || token == ":month" || token == ":day"
+		if needsDate && date.IsZero() {
+			err = fmt.Errorf("permalink %q needs a date in the front matter", pattern)
+			return ""
+		}
+		switch token {
+		case ":year":
+			return date.Format("2006")
+		case ":month":
+			return date.Format("01")
+		case ":day":
+			return date.Format("02")
+		case ":slug":
+			return pageSlug(p)
+		case ":filename":
+			return strings.TrimSuffix(path.Base(p.RelPath), ".md")
+		case ":section":
+			if i := strings.Index(p.RelPath, "/"); i >= 0 {
+				return p.RelPath[:i]
+			}
+			return ""
+		}
+		err = fmt.Errorf("permalink %q: unknown placeholder %s (use :year, :month, :day, :slug, :section or :filename)", pattern, token)
+		return ""
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(path.Clean("/"+expanded), "/"), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// publishStatus reports why p is not published at now: "draft",
+// "scheduled" (publishDate still ahead) or "expired" (expiryDate passed).
+// Published pages return "".
+func publishStatus(p *PageData, now time.Time) string {
+	fm := p.FrontMatter
+	switch {
+	case fm.Draft:
+		return "draft"
+	case !fm.ParsedPublishDate.IsZero() && now.Before(fm.ParsedPublishDate):
+		return "scheduled"
+	case !fm.ParsedExpiryDate.IsZero() && !now.Before(fm.ParsedExpiryDate):
+		return "expired"
+	}
+	return ""
+}
+
+// filterUnpublished sets Status on every page and drops the unpublished
+// ones opts doesn't ask for. Pages that stay carry their Status so the
+// layouts can show a banner.
+func filterUnpublished(pages []*PageData, opts buildOptions, now time.Time) []*PageData {
+	kept := pages[:0]
+	for _, p := range pages {
+		p.Status = publishStatus(p, now)
+		include := true
+		switch p.Status {
+		case "draft":
+			include = opts.Drafts
+		case "scheduled":
+			include = opts.Future
+		case "expired":
+			include = opts.Expired
+		}
+		if !include {
+			fmt.Printf("Skipped %s page: %s\n", p.Status, p.RelPath)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
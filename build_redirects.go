@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// redirectsFileName is the Netlify / Cloudflare Pages redirect list,
+// written when redirectsFile is set in config.yaml.
+const redirectsFileName = "_redirects"
+
+// redirect is one old URL kept alive by a stub page.
+type redirect struct {
+	From   string // site path, e.g. "/old-post/"
+	To     string // site path or absolute URL
+	Source string // where it was declared, for error messages
+}
+
+// stubFile is the output file of the stub for r, relative to the output
+// directory: "/old/" becomes "old/index.html", "/old.html" stays.
+func (r redirect) stubFile() string {
+	p := strings.Trim(r.From, "/")
+	if path.Ext(p) == ".html" {
+		return p
+	}
+	return path.Join(p, "index.html")
+}
+
+// siteRedirects collects the aliases of every page and the redirects from
+// config.yaml, sorted by old path.
+func siteRedirects(cache *BuildCache) ([]redirect, error) {
+	var redirects []redirect
+	for _, p := range cache.Pages {
+		for _, alias := range p.FrontMatter.Aliases {
+			redirects = append(redirects, redirect{
+				From:   normalizeRedirectPath(alias),
+				To:     pagePath(cache, p),
+				Source: fmt.Sprintf("alias in %s", p.RelPath),
+			})
+		}
+	}
+	for _, from := range sortedKeys(cache.Config.Redirects) {
+		to := strings.TrimSpace(cache.Config.Redirects[from])
+		switch {
+		case isExternalURL(to):
+		case strings.HasSuffix(strings.ToLower(to), ".md"):
+			target := findPage(cache, strings.TrimPrefix(to, "/"))
+			if target == nil {
+				return nil, fmt.Errorf("redirect %s in config.yaml: no page %s", from, to)
+			}
+			to = pagePath(cache, target)
+		default:
+			to = normalizeRedirectPath(to)
+		}
+		redirects = append(redirects, redirect{
+			From:   normalizeRedirectPath(from),
+			To:     to,
+			Source: fmt.Sprintf("redirect %s in config.yaml", from),
+		})
+	}
+	sort.SliceStable(redirects, func(i, j int) bool { return redirects[i].From < redirects[j].From })
+	return redirects, nil
+}
+
+// normalizeRedirectPath makes "old/post" and "/old/post/" both "/old/post/".
+// Paths to .html files keep their file name.
+func normalizeRedirectPath(p string) string {
+	p = "/" + strings.Trim(strings.TrimSpace(p), "/")
+	if p != "/" && path.Ext(p) != ".html" {
+		p += "/"
+	}
+	return p
+}
+
+// findPage returns the page built from the source file relPath, or nil.
+func findPage(cache *BuildCache, relPath string) *PageData {
+	for _, p := range cache.Pages {
+		if p.RelPath == relPath {
+			return p
+		}
+	}
+	return nil
+}
+
+var redirectStubTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Redirecting&hellip;</title>
+<link rel="canonical" href="{{.Canonical}}">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.Target}}">
+</head>
+<body>
+<p>This page has moved to <a href="{{.Target}}">{{.Target}}</a>.</p>
+</body>
+</html>
+`))
+
+// generateRedirects writes a meta refresh stub at the old path of every
+// redirect, plus _redirects when enabled.
+func generateRedirects(cache *BuildCache, outputDirRoot string, redirects []redirect) error {
+	var written []string
+	for _, r := range redirects {
+		target, canonical := r.To, r.To
+		if !isExternalURL(r.To) {
+			target = sitePath(r.To)
+			canonical = absoluteURL(cache, r.To)
+		}
+		var buf bytes.Buffer
+		if err := redirectStubTemplate.Execute(&buf, struct{ Target, Canonical string }{target, canonical}); err != nil {
+			return err
+		}
+		file := filepath.Join(outputDirRoot, filepath.FromSlash(r.stubFile()))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		written = append(written, relOutput(outputDirRoot, file))
+		fmt.Printf("Generated: %s (redirect to %s)\n", file, target)
+	}
+
+	if cache.Config.RedirectsFile {
+		var buf bytes.Buffer
+		for _, r := range redirects {
+			to := r.To
+			if !isExternalURL(to) {
+				to = sitePath(to)
+			}
+			fmt.Fprintf(&buf, "%s %s 301\n", sitePath(r.From), to)
+		}
+		file := filepath.Join(outputDirRoot, redirectsFileName)
+		if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		written = append(written, redirectsFileName)
+		fmt.Printf("Generated: %s\n", file)
+	}
+
+	cache.NextManifest.recordGenerated("#redirects", written)
+	return nil
+}
@@ -3,19 +3,19 @@ package main
 import (
 	"fmt"
 	"html/template"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-	"time"
-
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/parser"
-	"github.com/gosimple/slug"
 )
 
-func processPages(cache *BuildCache, outputDirRoot string) error {
+// assignOutputDirs decides where every Markdown page is written: index.md
+// at its directory, other pages at the permalink pattern for their
+// directory or <directory>/<slug>/. It must run before links are resolved
+// or any page is rendered.
+func assignOutputDirs(cache *BuildCache, outputDirRoot string) error {
 	for _, p := range cache.Pages {
 		base := filepath.Base(p.RelPath)
 		if base == "index.md" {
@@ -30,79 +30,86 @@ func processPages(cache *BuildCache, outputDirRoot string) error {
 
 		if p.IsIndex {
 			p.OutputDir = filepath.Join(outputDirRoot, dir)
-		} else {
-			slugTitle := slug.Make(p.FrontMatter.Title)
-			if slugTitle == "" {
-				slugTitle = strings.TrimSuffix(base, ".md")
+		} else if pattern := permalinkPattern(cache.Config, p); pattern != "" {
+			rel, err := expandPermalink(pattern, p)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p.RelPath, err)
 			}
-			p.OutputDir = filepath.Join(outputDirRoot, dir, slugTitle)
+			p.OutputDir = filepath.Join(outputDirRoot, filepath.FromSlash(rel))
+		} else {
+			p.OutputDir = filepath.Join(outputDirRoot, dir, pageSlug(p))
 		}
 	}
+	return nil
+}
 
-	for _, p := range cache.Pages {
-		p.MarkdownContent = fixLinksAndImages(cache, p)
+// processPages rewrites links, converts Markdown to HTML and renders every
+// page in cache.Pages, and the extra pages of paginated lists, on a pool of
+// jobs workers. All pages are converted before any is rendered, since list
+// pages may show other pages' content.
+func processPages(cache *BuildCache, outputDirRoot string, jobs int) error {
+	err := forEachPage(cache.Pages, jobs, func(p *PageData) error {
+		p.HTMLContent = renderMarkdown(cache, p)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	for _, p := range cache.Pages {
-		mdParser := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs)
-		htmlBytes := markdown.ToHTML(p.MarkdownContent, mdParser, nil)
-		p.HTMLContent = template.HTML(htmlBytes)
-
-		if err := renderHTMLPage(cache, p, outputDirRoot); err != nil {
-			return err
+	pages := allPages(cache)
+	for _, p := range pages {
+		if p.isExtraPage() {
+			p.HTMLContent = p.Pager.First().HTMLContent
 		}
 	}
-	return nil
+	return forEachPage(pages, jobs, func(p *PageData) error {
+		return renderHTMLPage(cache, p, outputDirRoot)
+	})
 }
 
-func renderHTMLPage(cache *BuildCache, page *PageData, siteBuildRoot string) error {
-	if err := os.MkdirAll(page.OutputDir, 0755); err != nil {
-		return err
-	}
-	outFile := filepath.Join(page.OutputDir, "index.html")
-	f, err := os.Create(outFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+// pageTemplateData is what the layouts are executed with.
+type pageTemplateData struct {
+	Config              *Config
+	Page                *PageData
+	MenuItems           []string
+	MenuTargets         []string
+	AlternativeCSSFiles []string
+	AlternativeJSFiles  []string
+}
+
+// siteTemplate holds the parsed layouts and the parts of their data that
+// are the same for every page. It is prepared once per build and shared by
+// all render workers.
+type siteTemplate struct {
+	layouts             map[string]*template.Template
+	menuItems           []string
+	menuTargets         []string
+	alternativeCSSFiles []string
+	alternativeJSFiles  []string
+}
+
+// prepareSiteTemplate parses the layout sources and resolves the menu.
+// Output directories must already be assigned.
+func prepareSiteTemplate(cache *BuildCache, siteBuildRoot string, layoutSources map[string]string) error {
+	st := &siteTemplate{}
 
-	var menuItems []string
-	var menuTargets []string
 	for _, item := range cache.Config.Menu {
-		menuItems = append(menuItems, item.Title)
+		st.menuItems = append(st.menuItems, item.Title)
 		outDir := FindPageByRelPath(cache, item.Path)
 		if outDir == "" {
-			if item.Path == "index.md" {
-				menuTargets = append(menuTargets, "/")
-			} else {
-				menuTargets = append(menuTargets, "/")
-			}
+			st.menuTargets = append(st.menuTargets, "/")
 		} else {
-			menuTargets = append(menuTargets, "/"+outDir+"/")
+			st.menuTargets = append(st.menuTargets, "/"+outDir+"/")
 		}
 	}
 
-	data := struct {
-		Config              *Config
-		Page                *PageData
-		MenuItems           []string
-		MenuTargets         []string
-		AlternativeCSSFiles []string
-		AlternativeJSFiles  []string
-	}{
-		Config:      cache.Config,
-		Page:        page,
-		MenuItems:   menuItems,
-		MenuTargets: menuTargets,
-	}
-
 	if cache.Config.Website.AlternativeCSSDir != "" {
 		files, err := os.ReadDir(cache.Config.Website.AlternativeCSSDir)
 		if err == nil { // Silently ignore errors here, build.go would have caught it
 			for _, file := range files {
 				if !file.IsDir() && filepath.Ext(file.Name()) == ".css" {
 					// Path for template should be relative to outputDir/css
-					data.AlternativeCSSFiles = append(data.AlternativeCSSFiles, "/css/"+file.Name())
+					st.alternativeCSSFiles = append(st.alternativeCSSFiles, "/css/"+file.Name())
 				}
 			}
 		}
@@ -114,97 +121,69 @@ func renderHTMLPage(cache *BuildCache, page *PageData, siteBuildRoot string) err
 			for _, file := range files {
 				if !file.IsDir() && filepath.Ext(file.Name()) == ".js" {
 					// Path for template should be relative to outputDir/js
-					data.AlternativeJSFiles = append(data.AlternativeJSFiles, "/js/"+file.Name())
+					st.alternativeJSFiles = append(st.alternativeJSFiles, "/js/"+file.Name())
 				}
 			}
 		}
 	}
 
-	tmpl := template.New("page")
-	tmpl = initTemplateFuncs(tmpl, cache, siteBuildRoot)
-	tmpl, err = tmpl.Parse(htmlTemplate)
+	layouts, err := parseLayouts(layoutSources, templateFuncs(cache, siteBuildRoot))
 	if err != nil {
 		return err
 	}
+	st.layouts = layouts
 
-	// Debug: Print BasePath to confirm it's loaded
-	fmt.Printf("DEBUG: BasePath in renderHTMLPage for page %s: [%s]\n", page.RelPath, cache.Config.Website.BasePath)
-
-	if err := tmpl.Execute(f, data); err != nil {
-		return err
-	}
-
-	fmt.Printf("Generated: %s\n", outFile)
+	cache.Template = st
 	return nil
 }
 
-// generate RSS => outputDirRoot/rss.xml
-func generateRSS(cache *BuildCache, outputDirRoot string) error {
-	var dated []*PageData
-	for _, p := range cache.Pages {
-		if !p.FrontMatter.ParsedDate.IsZero() {
-			dated = append(dated, p)
+// executePageTemplate renders page with its layout.
+func executePageTemplate(cache *BuildCache, page *PageData, w io.Writer) error {
+	st := cache.Template
+	name := page.layoutName()
+	tmpl, ok := st.layouts[name]
+	if !ok {
+		available := make([]string, 0, len(st.layouts))
+		for n := range st.layouts {
+			available = append(available, n)
 		}
+		sort.Strings(available)
+		return fmt.Errorf("unknown layout %q (available layouts: %s)", name, strings.Join(available, ", "))
 	}
-	sort.Slice(dated, func(i, j int) bool {
-		return dated[i].FrontMatter.ParsedDate.After(dated[j].FrontMatter.ParsedDate)
+	return tmpl.ExecuteTemplate(w, "base", pageTemplateData{
+		Config:              cache.Config,
+		Page:                page,
+		MenuItems:           st.menuItems,
+		MenuTargets:         st.menuTargets,
+		AlternativeCSSFiles: st.alternativeCSSFiles,
+		AlternativeJSFiles:  st.alternativeJSFiles,
 	})
+}
 
-	var items []string
-	for _, p := range dated {
-		pubDate := p.FrontMatter.ParsedDate.Format(time.RFC1123Z)
-		title := escapeForXML(p.FrontMatter.Title)
-		desc := escapeForXML(p.FrontMatter.Description)
-
-		// p.OutputDir is now like /tmp/krems-run-XYZ/actual/path
-		// We need to make it relative to outputDirRoot for the URL
-		relOut, err := filepath.Rel(outputDirRoot, p.OutputDir)
-		if err != nil {
-			// This should not happen if p.OutputDir is correctly prefixed
-			return fmt.Errorf("could not make path %s relative to %s: %w", p.OutputDir, outputDirRoot, err)
-		}
-		link := strings.TrimSuffix(cache.Config.Website.URL, "/") + "/" + filepath.ToSlash(relOut) + "/"
-		if p.IsIndex && relOut == "." { // Handle root index.md case
-			link = strings.TrimSuffix(cache.Config.Website.URL, "/") + "/"
-		}
-
+func renderHTMLPage(cache *BuildCache, page *PageData, siteBuildRoot string) error {
+	entry := newPageManifestEntry(cache, page)
+	if cache.Manifest.upToDate(entry, siteBuildRoot) {
+		cache.NextManifest.record(entry, false)
+		return nil
+	}
 
-		var enclosure string
-		if p.FrontMatter.Image != "" {
-			img := strings.TrimPrefix(p.FrontMatter.Image, "/")
-			enclosure = fmt.Sprintf(`<enclosure url="%s/%s" type="image/png"/>`,
-				strings.TrimSuffix(cache.Config.Website.URL, "/"), img)
-		}
-		items = append(items, fmt.Sprintf(`
-<item>
-  <title>%s</title>
-  <link>%s</link>
-  <description>%s</description>
-  <pubDate>%s</pubDate>
-  %s
-</item>
-`, title, link, desc, pubDate, enclosure))
+	if err := os.MkdirAll(page.OutputDir, 0755); err != nil {
+		return err
 	}
+	outFile := filepath.Join(page.OutputDir, "index.html")
+	f, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	rss := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0">
-<channel>
-  <title>%s</title>
-  <link>%s</link>
-  <description>RSS feed for %s</description>
-  %s
-</channel>
-</rss>
-`, escapeForXML(cache.Config.Website.Name),
-		cache.Config.Website.URL,
-		escapeForXML(cache.Config.Website.Name),
-		strings.Join(items, "\n"))
-
-	rssPath := filepath.Join(outputDirRoot, "rss.xml")
-	if err := os.WriteFile(rssPath, []byte(rss), 0644); err != nil {
+	if err := executePageTemplate(cache, page, f); err != nil {
 		return err
 	}
-	fmt.Printf("Generated: %s\n", rssPath)
+
+	entry.Outputs = []string{relOutput(siteBuildRoot, outFile)}
+	cache.NextManifest.record(entry, true)
+	fmt.Printf("Generated: %s\n", outFile)
 	return nil
 }
 
@@ -223,6 +202,7 @@ func create404Page(cache *BuildCache, outputDirRoot string) error {
 		},
 		RelPath:   "404.html",
 		OutputDir: outputDirRoot,
+		kind:      "404",
 	}
 
 	var homeLinkFor404Page string
@@ -236,67 +216,7 @@ func create404Page(cache *BuildCache, outputDirRoot string) error {
 <p>Go <a href="%s">home</a> to find what you're looking for</p>
 `, homeLinkFor404Page))
 
-	var menuItems []string
-	var menuTargets []string
-	for _, item := range cache.Config.Menu {
-		menuItems = append(menuItems, item.Title)
-		outDir := FindPageByRelPath(cache, item.Path)
-		if outDir == "" {
-			if item.Path == "index.md" {
-				menuTargets = append(menuTargets, "/")
-			} else {
-				menuTargets = append(menuTargets, "/")
-			}
-		} else {
-			menuTargets = append(menuTargets, "/"+outDir+"/")
-		}
-	}
-
-	data := struct {
-		Config              *Config
-		Page                *PageData
-		MenuItems           []string
-		MenuTargets         []string
-		AlternativeCSSFiles []string // For consistency, though 404 might not use them
-		AlternativeJSFiles  []string // For consistency
-	}{
-		Config:      cache.Config,
-		Page:        pseudo,
-		MenuItems:   menuItems,
-		MenuTargets: menuTargets,
-	}
-
-	// Populate alternative files for 404 page as well, for template consistency
-	// even if not strictly used by a minimal 404.
-	if cache.Config.Website.AlternativeCSSDir != "" {
-		files, err := os.ReadDir(cache.Config.Website.AlternativeCSSDir)
-		if err == nil {
-			for _, file := range files {
-				if !file.IsDir() && filepath.Ext(file.Name()) == ".css" {
-					data.AlternativeCSSFiles = append(data.AlternativeCSSFiles, "/css/"+file.Name())
-				}
-			}
-		}
-	}
-
-	if cache.Config.Website.AlternativeJSDir != "" {
-		files, err := os.ReadDir(cache.Config.Website.AlternativeJSDir)
-		if err == nil {
-			for _, file := range files {
-				if !file.IsDir() && filepath.Ext(file.Name()) == ".js" {
-					data.AlternativeJSFiles = append(data.AlternativeJSFiles, "/js/"+file.Name())
-				}
-			}
-		}
-	}
-
-	tmpl := template.New("404")
-	tmpl = initTemplateFuncs(tmpl, cache, outputDirRoot)
-	tmpl, err = tmpl.Parse(htmlTemplate)
-	if err != nil {
-		return err
-	}
-	if err := tmpl.Execute(f, data); err != nil {
+	if err := executePageTemplate(cache, pseudo, f); err != nil {
 		return err
 	}
 
@@ -323,17 +243,6 @@ func extractDomain(urlStr string) string {
 	return host
 }
 
-func escapeForXML(s string) string {
-	r := strings.NewReplacer(
-		`&`, "&amp;",
-		`<`, "&lt;",
-		`>`, "&gt;",
-		`"`, "&quot;",
-		`'`, "&apos;",
-	)
-	return r.Replace(s)
-}
-
 func trimPrefixSlash(s string) string {
 	return strings.TrimPrefix(s, "/")
 }
@@ -394,9 +303,10 @@ func imagePath(pageOutputDir, siteBuildRoot, img string) string {
 // The `page` object has `OutputDir`.
 // We need to pass `siteBuildRoot` to the template functions.
 
-// Modified initTemplateFuncs to pass siteBuildRoot and cache
-func initTemplateFuncs(t *template.Template, cache *BuildCache, siteBuildRoot string) *template.Template {
-	return t.Funcs(template.FuncMap{
+// templateFuncs returns the functions available to layouts.
+// It needs siteBuildRoot and cache for the path helpers.
+func templateFuncs(cache *BuildCache, siteBuildRoot string) template.FuncMap {
+	return template.FuncMap{
 		"trimPrefixSlash":      trimPrefixSlash,
 		"relativeToRoot":       func(pageOutputDir string) string { return relativeToRoot(pageOutputDir, siteBuildRoot) },
 		"imagePath":            func(pageOutputDir, img string) string { return imagePath(pageOutputDir, siteBuildRoot, img) },
@@ -406,27 +316,9 @@ func initTemplateFuncs(t *template.Template, cache *BuildCache, siteBuildRoot st
 		"authorLine":           authorLine,
 		"dateDisplay":          dateDisplay,
 		"sitePath":             sitePath, // Directly use the sitePath Go function
-	})
+		"pageFeedDir":          func(p *PageData) string { return pageFeedDir(cache, p) },
+		"pageURL":              func(p *PageData) string { return sitePath(pagePath(cache, p)) },
+		"listedPages":          func(p *PageData) []*PageData { return p.listedPages(cache) },
+		"groupByMonth":         groupByYearThenMonth,
+	}
 }
-
-// renderHTMLPage needs to pass siteBuildRoot to initTemplateFuncs
-// The siteBuildRoot is the `page.OutputDir`'s root part, which is `outputDirRoot` from `processPages`.
-// However, renderHTMLPage is called for each page, and `page.OutputDir` is specific.
-// The `siteBuildRoot` is the one passed to `handleBuild`.
-// So, `renderHTMLPage` needs `siteBuildRoot` as a parameter.
-// And `processPages` needs to pass it to `renderHTMLPage`.
-
-// Let's adjust renderHTMLPage signature and call
-// renderHTMLPage(cache *BuildCache, page *PageData, siteBuildRoot string)
-
-// In processPages:
-// if err := renderHTMLPage(cache, p, outputDirRoot); err != nil { return err }
-
-// Then in renderHTMLPage:
-// tmpl = initTemplateFuncs(tmpl, siteBuildRoot)
-// This seems more robust.
-
-// The definition of sitePath is not in this file, I'll assume it's in htmlTemplate.go or similar
-// and correctly uses BasePath. The main concern is that BasePath itself is set correctly
-// (which handleBuild does: `if isDevMode && cfg.Website.DevPath != "" { cfg.Website.BasePath = cfg.Website.DevPath }`)
-// and that relative paths generated by `imagePath` etc. work from the served location.
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// searchDir holds the search page and its index files.
+const searchDir = "search"
+
+// searchEntry is one page in the search index. It holds plain text only,
+// since the index is downloaded by every reader who searches.
+type searchEntry struct {
+	Title  string   `json:"title"`
+	URL    string   `json:"url"`
+	Tags   []string `json:"tags,omitempty"`
+	Author string   `json:"author,omitempty"`
+	Date   string   `json:"date,omitempty"` // 2006-01-02
+	Text   string   `json:"text"`
+}
+
+// searchShard is one index file: the whole site, or with search.shards
+// one top-level directory.
+type searchShard struct {
+	Name  string `json:"name"`  // directory, "" for pages in the site root
+	Title string `json:"title"` // the title of its index.md, else Name
+	URL   string `json:"url"`   // site path of the index file
+
+	file  string // relative to the output directory
+	pages []*PageData
+}
+
+// searchIndex is search/index.json: the pages themselves, or the shards
+// to load when the index is split.
+type searchIndex struct {
+	Pages    []searchEntry `json:"pages,omitempty"`
+	Sections []searchShard `json:"sections,omitempty"`
+}
+
+// addSearchPage registers the generated /search/ page when search is
+// enabled.
+func addSearchPage(cache *BuildCache, outputDirRoot string) {
+	if !cache.Config.Search.Enabled {
+		return
+	}
+	pseudo := &PageData{
+		FrontMatter: PageFrontMatter{Title: "Search", Type: "normal"},
+		RelPath:     path.Join(searchDir, "index.md"),
+		OutputDir:   filepath.Join(outputDirRoot, searchDir),
+		kind:        "search",
+	}
+	pseudo.FrontMatterHash = hashBytes([]byte(pseudo.FrontMatter.Title))
+	cache.Pages = append(cache.Pages, pseudo)
+}
+
+// searchShards groups the searchable pages, those written from Markdown
+// files, into index files.
+func searchShards(cache *BuildCache) []*searchShard {
+	if !cache.Config.Search.Enabled {
+		return nil
+	}
+	if !cache.Config.Search.Shards {
+		shard := &searchShard{file: path.Join(searchDir, "index.json")}
+		for _, p := range cache.Pages {
+			if p.kind == "" {
+				shard.pages = append(shard.pages, p)
+			}
+		}
+		return []*searchShard{shard}
+	}
+
+	var shards []*searchShard
+	byName := make(map[string]*searchShard)
+	for _, p := range cache.Pages {
+		if p.kind != "" {
+			continue
+		}
+		name := ""
+		if i := strings.Index(p.RelPath, "/"); i >= 0 {
+			name = p.RelPath[:i]
+		}
+		shard := byName[name]
+		if shard == nil {
+			shard = &searchShard{Name: name, Title: name, file: path.Join(searchDir, "section-"+slug.Make(name)+".json")}
+			if name == "" {
+				shard.Title = "Top level"
+				shard.file = path.Join(searchDir, "root.json")
+			}
+			if index := findPage(cache, path.Join(name, "index.md")); index != nil && index.FrontMatter.Title != "" {
+				shard.Title = index.FrontMatter.Title
+			}
+			shard.URL = sitePath("/" + shard.file)
+			byName[name] = shard
+			shards = append(shards, shard)
+		}
+		shard.pages = append(shard.pages, p)
+	}
+	return shards
+}
+
+// searchIndexFiles lists the index files the build writes, relative to the
+// output directory.
+func searchIndexFiles(cache *BuildCache) []string {
+	shards := searchShards(cache)
+	if len(shards) == 0 {
+		return nil
+	}
+	files := []string{path.Join(searchDir, "index.json")}
+	if cache.Config.Search.Shards {
+		for _, s := range shards {
+			files = append(files, s.file)
+		}
+	}
+	return files
+}
+
+// generateSearchIndex writes search/index.json and, with search.shards,
+// one file per top-level directory. Pages must be converted to HTML.
+func generateSearchIndex(cache *BuildCache, outputDirRoot string) error {
+	shards := searchShards(cache)
+	if len(shards) == 0 {
+		return nil
+	}
+
+	var written []string
+	write := func(file string, v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(outputDirRoot, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, data, 0644); err != nil {
+			return err
+		}
+		written = append(written, file)
+		fmt.Printf("Generated: %s\n", out)
+		return nil
+	}
+
+	if !cache.Config.Search.Shards {
+		if err := write(shards[0].file, searchIndex{Pages: searchEntries(cache, shards[0].pages)}); err != nil {
+			return err
+		}
+	} else {
+		index := searchIndex{}
+		for _, s := range shards {
+			if err := write(s.file, searchIndex{Pages: searchEntries(cache, s.pages)}); err != nil {
+				return err
+			}
+			index.Sections = append(index.Sections, *s)
+		}
+		if err := write(path.Join(searchDir, "index.json"), index); err != nil {
+			return err
+		}
+	}
+
+	cache.NextManifest.recordGenerated("#search", written)
+	return nil
+}
+
+func searchEntries(cache *BuildCache, pages []*PageData) []searchEntry {
+	entries := make([]searchEntry, 0, len(pages))
+	for _, p := range pages {
+		e := searchEntry{
+			Title:  p.FrontMatter.Title,
+			URL:    sitePath(pagePath(cache, p)),
+			Tags:   p.FrontMatter.Tags,
+			Author: p.FrontMatter.Author,
+			Text:   plainText(string(p.HTMLContent)),
+		}
+		if !p.FrontMatter.ParsedDate.IsZero() {
+			e.Date = p.FrontMatter.ParsedDate.Format("2006-01-02")
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
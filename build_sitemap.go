@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// generateSitemap => outputDirRoot/sitemap.xml, listing every rendered page
+// including the generated tag and author pages.
+func generateSitemap(cache *BuildCache, outputDirRoot string) error {
+	if !boolOr(cache.Config.Sitemap.Enabled, true) {
+		return nil
+	}
+
+	set := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, p := range cache.Pages {
+		if p.kind == "search" {
+			continue
+		}
+		u := sitemapURL{Loc: absoluteURL(cache, pagePath(cache, p))}
+		if !p.FrontMatter.ParsedUpdated.IsZero() {
+			u.LastMod = p.FrontMatter.ParsedUpdated.Format(time.RFC3339)
+		} else if !p.FrontMatter.ParsedDate.IsZero() {
+			u.LastMod = p.FrontMatter.ParsedDate.Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, u)
+	}
+	sort.Slice(set.URLs, func(i, j int) bool { return set.URLs[i].Loc < set.URLs[j].Loc })
+
+	out, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	sitemapPath := filepath.Join(outputDirRoot, "sitemap.xml")
+	if err := os.WriteFile(sitemapPath, append([]byte(xml.Header), append(out, '\n')...), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Generated: %s\n", sitemapPath)
+	return nil
+}
+
+// generateRobots => outputDirRoot/robots.txt, pointing crawlers at the sitemap.
+func generateRobots(cache *BuildCache, outputDirRoot string) error {
+	if !boolOr(cache.Config.Robots.Enabled, true) {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("User-agent: *\n")
+	if len(cache.Config.Robots.Disallow) == 0 {
+		sb.WriteString("Disallow:\n")
+	}
+	for _, path := range cache.Config.Robots.Disallow {
+		sb.WriteString("Disallow: " + sitePath(path) + "\n")
+	}
+	if boolOr(cache.Config.Sitemap.Enabled, true) {
+		sb.WriteString("\nSitemap: " + absoluteURL(cache, "/sitemap.xml") + "\n")
+	}
+
+	robotsPath := filepath.Join(outputDirRoot, "robots.txt")
+	if err := os.WriteFile(robotsPath, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Generated: %s\n", robotsPath)
+	return nil
+}
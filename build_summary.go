@@ -0,0 +1,58 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	excerptLength  = 200 // characters, cut at a word boundary
+	wordsPerMinute = 200
+	moreMarker     = "<!--more-->"
+)
+
+var (
+	reHTMLTag  = regexp.MustCompile(`<[^>]*>`)
+	reBlockTag = regexp.MustCompile(`(?i)^</?(p|div|h[1-6]|li|dt|dd|td|th|tr|br|hr|blockquote|details|summary|pre|ul|ol|dl|table|figure|figcaption)\b`)
+)
+
+// plainText strips the tags from rendered HTML and collapses whitespace.
+// Block tags become spaces, so "<td>a</td><td>b</td>" is "a b" while
+// "<em>a</em>." stays "a.".
+func plainText(s string) string {
+	s = reHTMLTag.ReplaceAllStringFunc(s, func(tag string) string {
+		if reBlockTag.MatchString(tag) {
+			return " "
+		}
+		return ""
+	})
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// Excerpt is a plain text summary of the page for list layouts: the text
+// before a <!--more--> line, else the first excerptLength characters.
+// It needs HTMLContent, so it is only useful once pages are converted.
+func (p *PageData) Excerpt() string {
+	content := string(p.HTMLContent)
+	if i := strings.Index(content, moreMarker); i >= 0 {
+		return plainText(content[:i])
+	}
+	text := plainText(content)
+	if utf8.RuneCountInString(text) <= excerptLength {
+		return text
+	}
+	cut := []rune(text)[:excerptLength]
+	if i := strings.LastIndex(string(cut), " "); i > 0 {
+		return string(cut)[:i] + "…"
+	}
+	return string(cut) + "…"
+}
+
+// ReadingTime is the estimated reading time of the page in minutes, at
+// least 1.
+func (p *PageData) ReadingTime() int {
+	words := len(strings.Fields(plainText(string(p.HTMLContent))))
+	return max(1, (words+wordsPerMinute-1)/wordsPerMinute)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	mdhtml "github.com/gomarkdown/markdown/html"
+)
+
+// tocMarker is a paragraph that places the table of contents inline.
+const tocMarker = "[[toc]]"
+
+// TOCEntry is one heading in a page's table of contents.
+type TOCEntry struct {
+	Level    int // 1 for #, 2 for ## and so on
+	ID       string
+	Title    string
+	Children []*TOCEntry // the headings below it, one or more levels deeper
+}
+
+// tocLevels returns the heading levels the table of contents of p covers:
+// the tocMinLevel and tocMaxLevel front matter, else the site's toc
+// settings, else ## to ###.
+func tocLevels(cfg *Config, p *PageData) (minLevel, maxLevel int) {
+	minLevel, maxLevel = 2, 3
+	if cfg.TOC.MinLevel > 0 {
+		minLevel = cfg.TOC.MinLevel
+	}
+	if cfg.TOC.MaxLevel > 0 {
+		maxLevel = cfg.TOC.MaxLevel
+	}
+	if p.FrontMatter.TOCMinLevel > 0 {
+		minLevel = p.FrontMatter.TOCMinLevel
+	}
+	if p.FrontMatter.TOCMaxLevel > 0 {
+		maxLevel = p.FrontMatter.TOCMaxLevel
+	}
+	return minLevel, maxLevel
+}
+
+// collectTOC sets page.TOC from the headings of doc and replaces every
+// [[toc]] paragraph with it. ids must be a renderer with the options the
+// page is rendered with, so the ids match the ones in the HTML.
+func collectTOC(cache *BuildCache, page *PageData, doc ast.Node, ids *mdhtml.Renderer) {
+	minLevel, maxLevel := tocLevels(cache.Config, page)
+	var roots, stack []*TOCEntry
+	var markers []*ast.Paragraph
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Heading:
+			if n.HeadingID == "" {
+				return ast.SkipChildren
+			}
+			// Every heading takes its id, listed or not, as the renderer does.
+			id := ids.Opts.HeadingIDPrefix + ids.EnsureUniqueHeadingID(n.HeadingID)
+			if n.IsTitleblock || n.Level < minLevel || n.Level > maxLevel {
+				return ast.SkipChildren
+			}
+			entry := &TOCEntry{Level: n.Level, ID: id, Title: nodeText(n)}
+			for len(stack) > 0 && stack[len(stack)-1].Level >= n.Level {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				roots = append(roots, entry)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, entry)
+			}
+			stack = append(stack, entry)
+			return ast.SkipChildren
+		case *ast.Paragraph:
+			if strings.TrimSpace(nodeText(n)) == tocMarker {
+				markers = append(markers, n)
+			}
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+	})
+
+	page.TOC = roots
+	page.tocInline = len(markers) > 0
+	for _, m := range markers {
+		block := &ast.HTMLBlock{}
+		block.Literal = []byte(page.TOCHTML())
+		replaceNode(m, block)
+	}
+}
+
+// replaceNode puts replacements where node is in the tree.
+func replaceNode(node ast.Node, replacements ...ast.Node) {
+	parent := node.GetParent()
+	children := parent.GetChildren()
+	for i, c := range children {
+		if c == node {
+			for _, r := range replacements {
+				r.SetParent(parent)
+			}
+			rest := append(replacements, children[i+1:]...)
+			parent.SetChildren(append(children[:i:i], rest...))
+			return
+		}
+	}
+}
+
+// nodeText concatenates the text and inline code inside node.
+func nodeText(node ast.Node) string {
+	var sb strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if entering {
+			switch t := n.(type) {
+			case *ast.Text:
+				sb.Write(t.Literal)
+			case *ast.Code:
+				sb.Write(t.Literal)
+			}
+		}
+		return ast.GoToNext
+	})
+	return sb.String()
+}
+
+// ShowTOC reports whether layouts should show the table of contents above
+// the page: it is enabled by toc front matter or the site's toc settings,
+// the page has headings in range and no [[toc]] marker placed it already.
+func (p *PageData) ShowTOC() bool {
+	if p.tocInline || len(p.TOC) == 0 {
+		return false
+	}
+	if p.FrontMatter.TOC != nil {
+		return *p.FrontMatter.TOC
+	}
+	return globalBuildCache != nil && globalBuildCache.Config.TOC.Enabled
+}
+
+// TOCHTML renders the table of contents as nested lists.
+func (p *PageData) TOCHTML() template.HTML {
+	if len(p.TOC) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(`<nav class="toc" aria-label="Table of contents">` + "\n")
+	writeTOCList(&sb, p.TOC)
+	sb.WriteString("</nav>\n")
+	return template.HTML(sb.String())
+}
+
+func writeTOCList(sb *strings.Builder, entries []*TOCEntry) {
+	sb.WriteString("<ul>\n")
+	for _, e := range entries {
+		fmt.Fprintf(sb, `<li><a href="#%s">%s</a>`, html.EscapeString(e.ID), html.EscapeString(e.Title))
+		if len(e.Children) > 0 {
+			sb.WriteString("\n")
+			writeTOCList(sb, e.Children)
+		}
+		sb.WriteString("</li>\n")
+	}
+	sb.WriteString("</ul>\n")
+}
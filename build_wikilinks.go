@@ -0,0 +1,518 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gosimple/slug"
+)
+
+// Obsidian links: [[Page]], [[Page|text]], [[Page#Heading]], [[#Heading]],
+// and embeds: ![[image.png]], ![[image.png|200]], ![[Other Note]].
+var reWikilink = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+
+// maxEmbedDepth limits notes embedded in embedded notes.
+const maxEmbedDepth = 3
+
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".avif": true, ".bmp": true,
+}
+
+// vault indexes the files of the site for wikilinks, which name files
+// without their directory, and collects the attachments to copy.
+type vault struct {
+	once   sync.Once
+	files  map[string][]string // lower-case base name => source paths
+	hash   string              // of every page's path and title, see pageSetHash
+	mu     sync.Mutex
+	copied map[string]bool // attachments referenced by the pages
+}
+
+// wikilink is one [[...]] parsed into its parts.
+type wikilink struct {
+	raw      string // as written, for warnings
+	embed    bool   // ![[...]]
+	target   string // file or page name, "" for [[#Heading]]
+	heading  string
+	label    string // the text after |
+	hasLabel bool
+}
+
+func parseWikilink(m []string) wikilink {
+	w := wikilink{raw: m[0], embed: m[1] == "!"}
+	inner := m[2]
+	if i := strings.Index(inner, "|"); i >= 0 {
+		w.label, w.hasLabel = strings.TrimSpace(inner[i+1:]), true
+		inner = inner[:i]
+	}
+	w.target = strings.TrimSpace(inner)
+	if i := strings.Index(w.target, "#"); i >= 0 {
+		w.heading = strings.TrimSpace(w.target[i+1:])
+		w.target = strings.TrimSpace(w.target[:i])
+	}
+	return w
+}
+
+// text is what a link shows: the label, else the name as Obsidian shows
+// it, "Page > Heading".
+func (w wikilink) text() string {
+	if w.hasLabel && w.label != "" {
+		return w.label
+	}
+	name := w.target
+	if strings.EqualFold(path.Ext(name), ".md") {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	switch {
+	case name == "":
+		return w.heading
+	case w.heading != "":
+		return name + " > " + w.heading
+	}
+	return name
+}
+
+// headingAnchor is the id the Markdown parser gives a heading with text.
+func headingAnchor(text string) string {
+	var anchor []rune
+	dash := false
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if dash && len(anchor) > 0 {
+				anchor = append(anchor, '-')
+			}
+			dash = false
+			anchor = append(anchor, unicode.ToLower(r))
+		} else {
+			dash = true
+		}
+	}
+	if len(anchor) == 0 {
+		return "empty"
+	}
+	return string(anchor)
+}
+
+// resolveWikilinks replaces the wikilinks in the text of doc with links,
+// images and embedded notes. embeddedIn lists the pages being rendered,
+// outermost first, to stop embed cycles.
+func resolveWikilinks(cache *BuildCache, page *PageData, doc ast.Node, embeddedIn []string) {
+	var texts []*ast.Text
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Link, *ast.Image:
+			return ast.SkipChildren
+		case *ast.Text:
+			if reWikilink.Match(n.Literal) {
+				texts = append(texts, n)
+			}
+		}
+		return ast.GoToNext
+	})
+
+	for _, t := range texts {
+		// A note embedded on its own line becomes a block.
+		if para, ok := t.GetParent().(*ast.Paragraph); ok && len(para.Children) == 1 {
+			if m := reWikilink.FindStringSubmatch(strings.TrimSpace(string(t.Literal))); m != nil && m[0] == strings.TrimSpace(string(t.Literal)) {
+				if block := embedNote(cache, page, parseWikilink(m), embeddedIn); block != nil {
+					replaceNode(para, block)
+					continue
+				}
+			}
+		}
+
+		var nodes []ast.Node
+		literal := string(t.Literal)
+		last := 0
+		for _, loc := range reWikilink.FindAllStringSubmatchIndex(literal, -1) {
+			m := []string{literal[loc[0]:loc[1]], literal[loc[2]:loc[3]], literal[loc[4]:loc[5]]}
+			if strings.EqualFold(strings.TrimSpace(m[2]), "toc") && m[1] == "" {
+				continue // the table of contents marker
+			}
+			if loc[0] > last {
+				nodes = append(nodes, textNode(literal[last:loc[0]]))
+			}
+			nodes = append(nodes, wikilinkNode(cache, page, parseWikilink(m)))
+			last = loc[1]
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		if last < len(literal) {
+			nodes = append(nodes, textNode(literal[last:]))
+		}
+		replaceNode(t, nodes...)
+	}
+}
+
+func textNode(s string) *ast.Text {
+	t := &ast.Text{}
+	t.Literal = []byte(s)
+	return t
+}
+
+// wikilinkNode turns one inline wikilink into a link, an image, or its
+// plain text when the target does not exist.
+func wikilinkNode(cache *BuildCache, page *PageData, w wikilink) ast.Node {
+	page.wikilinks = true
+	ext := strings.ToLower(path.Ext(w.target))
+	if w.target != "" && ext != "" && ext != ".md" {
+		file := findVaultFile(cache, page, w.target)
+		if file == "" {
+			page.addProblem(w.raw, "missing file %q", w.target)
+			return textNode(w.text())
+		}
+		src := attachmentPath(cache, file)
+		if w.embed && imageExtensions[ext] {
+			return imageNode(src, w)
+		}
+		return linkNode(src, w.text())
+	}
+
+	anchor := ""
+	if w.heading != "" {
+		anchor = "#" + headingAnchor(w.heading)
+	}
+	if w.target == "" && anchor != "" {
+		anchor = "#" + page.idPrefix + anchor[1:]
+	}
+	if w.target == "" {
+		return linkNode(anchor, w.text())
+	}
+	target := findWikiPage(cache, page, w.target)
+	if target == nil {
+		page.addProblem(w.raw, "unresolved wikilink %q", w.raw)
+		return textNode(w.text())
+	}
+	page.linkTargets = append(page.linkTargets, target.RelPath)
+	return linkNode(sitePath(pagePath(cache, target))+anchor, w.text())
+}
+
+func linkNode(dest, text string) *ast.Link {
+	link := &ast.Link{Destination: []byte(dest), AdditionalAttributes: []string{`class="wikilink"`}}
+	ast.AppendChild(link, textNode(text))
+	return link
+}
+
+// imageNode is an embedded image. The label is a width ("200") or a size
+// ("200x100") as in Obsidian, else the alt text.
+func imageNode(src string, w wikilink) ast.Node {
+	var width, height string
+	if w.hasLabel {
+		width, height, _ = strings.Cut(w.label, "x")
+		if !isDigits(width) || (height != "" && !isDigits(height)) {
+			width, height = "", ""
+		}
+	}
+	if width == "" {
+		img := &ast.Image{Destination: []byte(src)}
+		alt := strings.TrimSuffix(path.Base(w.target), path.Ext(w.target))
+		if w.hasLabel {
+			alt = w.label
+		}
+		ast.AppendChild(img, textNode(alt))
+		return img
+	}
+	size := fmt.Sprintf(` width="%s"`, width)
+	if height != "" {
+		size += fmt.Sprintf(` height="%s"`, height)
+	}
+	span := &ast.HTMLSpan{}
+	span.Literal = []byte(fmt.Sprintf(`<img src="%s" alt="%s"%s style="max-width:100%%;height:auto;" class="mb-3 img-fluid"/>`,
+		html.EscapeString(src), html.EscapeString(strings.TrimSuffix(path.Base(w.target), path.Ext(w.target))), size))
+	return span
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// embedNote renders the note (or the section under #heading) a ![[Note]]
+// on its own line points to. It returns nil for anything else, which is
+// then handled as an inline wikilink.
+func embedNote(cache *BuildCache, page *PageData, w wikilink, embeddedIn []string) ast.Node {
+	ext := strings.ToLower(path.Ext(w.target))
+	if !w.embed || w.target == "" || (ext != "" && ext != ".md") {
+		return nil
+	}
+	page.wikilinks = true
+	target := findWikiPage(cache, page, w.target)
+	if target == nil {
+		return nil
+	}
+	for _, rel := range embeddedIn {
+		if rel == target.RelPath {
+			page.addProblem(w.raw, "%s embeds itself", w.raw)
+			return nil
+		}
+	}
+	if len(embeddedIn) > maxEmbedDepth {
+		return nil
+	}
+
+	// A fresh page for the note, so its links resolve from its own directory
+	// and nothing is written to the note's PageData, which another worker
+	// may be rendering. Its heading ids get a prefix, numbered when the
+	// note is embedded more than once.
+	prefix := page.idPrefix + slug.Make(strings.TrimSuffix(path.Base(target.RelPath), ".md"))
+	if n := countOf(page.embeds, target.RelPath); n > 0 {
+		prefix += fmt.Sprintf("-%d", n+1)
+	}
+	note := &PageData{
+		FrontMatter:     target.FrontMatter,
+		MarkdownContent: target.MarkdownContent,
+		RelPath:         target.RelPath,
+		OutputDir:       target.OutputDir,
+		bodyLine:        target.bodyLine,
+		idPrefix:        prefix + "-",
+	}
+	section := ""
+	if w.heading != "" {
+		section = headingAnchor(w.heading)
+	}
+	content := renderPage(cache, note, section, embeddedIn)
+
+	page.linkTargets = append(page.linkTargets, target.RelPath)
+	page.linkTargets = append(page.linkTargets, note.linkTargets...)
+	page.embeds = append(page.embeds, target.RelPath)
+	page.embeds = append(page.embeds, note.embeds...)
+	page.wikilinks = page.wikilinks || note.wikilinks
+
+	block := &ast.HTMLBlock{}
+	block.Literal = []byte(fmt.Sprintf("<div class=\"wikilink-embed\" data-source=\"%s\">\n%s</div>",
+		html.EscapeString(sitePath(pagePath(cache, target))), content))
+	return block
+}
+
+func countOf(list []string, s string) int {
+	n := 0
+	for _, v := range list {
+		if v == s {
+			n++
+		}
+	}
+	return n
+}
+
+// keepSection removes everything from doc but the heading with id anchor
+// and what follows it up to the next heading of the same or a higher
+// level. Without such a heading doc is left alone.
+func keepSection(doc ast.Node, anchor string) {
+	children := doc.GetChildren()
+	start := -1
+	level := 0
+	for i, c := range children {
+		h, ok := c.(*ast.Heading)
+		if !ok {
+			continue
+		}
+		if start < 0 && h.HeadingID == anchor {
+			start, level = i, h.Level
+			continue
+		}
+		if start >= 0 && h.Level <= level {
+			doc.SetChildren(children[start:i])
+			return
+		}
+	}
+	if start >= 0 {
+		doc.SetChildren(children[start:])
+	}
+}
+
+// findWikiPage finds the page a wikilink names: by path relative to the
+// linking page, by path or file name anywhere in the site (in Obsidian
+// "Note" is enough for "notes/2025/Note.md"), or by title. Ties go to the
+// page in the linking page's directory, then to the shortest path.
+func findWikiPage(cache *BuildCache, page *PageData, name string) *PageData {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "/"), ".md")
+	lower := strings.ToLower(name)
+	fromPage := path.Join(path.Dir(page.RelPath), name)
+
+	var best *PageData
+	bestRank := 0
+	for _, p := range cache.Pages {
+		if p.kind != "" {
+			continue
+		}
+		noExt := strings.TrimSuffix(p.RelPath, ".md")
+		rank := 0
+		switch {
+		case noExt == fromPage:
+			rank = 1
+		case strings.HasSuffix("/"+strings.ToLower(noExt), "/"+lower):
+			rank = 2
+		case strings.EqualFold(strings.TrimSpace(p.FrontMatter.Title), name):
+			rank = 3
+		default:
+			continue
+		}
+		if best == nil || rank < bestRank || (rank == bestRank && closerPath(page, p, best)) {
+			best, bestRank = p, rank
+		}
+	}
+	return best
+}
+
+// closerPath reports whether a is a better match than b for a link from
+// page: in the same directory, else the shorter path.
+func closerPath(page, a, b *PageData) bool {
+	dir := path.Dir(page.RelPath)
+	if aSame, bSame := path.Dir(a.RelPath) == dir, path.Dir(b.RelPath) == dir; aSame != bSame {
+		return aSame
+	}
+	if len(a.RelPath) != len(b.RelPath) {
+		return len(a.RelPath) < len(b.RelPath)
+	}
+	return a.RelPath < b.RelPath
+}
+
+// findVaultFile finds a file a wikilink names: relative to the page, to
+// the site root, or by file name anywhere in the site. Files outside the
+// site are never found.
+func findVaultFile(cache *BuildCache, page *PageData, name string) string {
+	name = strings.TrimPrefix(name, "/")
+	for _, candidate := range []string{path.Join(path.Dir(page.RelPath), name), path.Clean(name)} {
+		if inSite(candidate) && fileExists(candidate) {
+			return candidate
+		}
+	}
+
+	v := siteVault(cache)
+	matches := v.files[strings.ToLower(path.Base(name))]
+	var found []string
+	for _, m := range matches {
+		if strings.HasSuffix("/"+strings.ToLower(m), "/"+strings.ToLower(name)) {
+			found = append(found, m)
+		}
+	}
+	if len(found) == 0 {
+		return ""
+	}
+	dir := path.Dir(page.RelPath)
+	sort.SliceStable(found, func(i, j int) bool {
+		if iSame, jSame := path.Dir(found[i]) == dir, path.Dir(found[j]) == dir; iSame != jSame {
+			return iSame
+		}
+		return len(found[i]) < len(found[j])
+	})
+	return found[0]
+}
+
+// inSite reports whether the cleaned, slash separated path rel stays in
+// the directory it is relative to: the site, or the output directory.
+func inSite(rel string) bool {
+	return rel != ".." && !strings.HasPrefix(rel, "../") && !path.IsAbs(rel) && !filepath.IsAbs(filepath.FromSlash(rel))
+}
+
+// siteVault indexes the site's files on first use. Hidden directories,
+// such as .obsidian and .git, and the output directory are skipped.
+func siteVault(cache *BuildCache) *vault {
+	v := &cache.vault
+	v.once.Do(func() {
+		v.files = make(map[string][]string)
+		v.copied = make(map[string]bool)
+		out := filepath.Clean(cache.CurrentBuildOutputDir)
+		_ = filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if p != "." && (strings.HasPrefix(d.Name(), ".") || filepath.Clean(p) == out) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(p) != ".md" {
+				rel := filepath.ToSlash(p)
+				key := strings.ToLower(path.Base(rel))
+				v.files[key] = append(v.files[key], rel)
+			}
+			return nil
+		})
+
+		var sb strings.Builder
+		for _, p := range cache.Pages {
+			fmt.Fprintf(&sb, "%s\x00%s\n", p.RelPath, p.FrontMatter.Title)
+		}
+		v.hash = hashBytes([]byte(sb.String()))
+	})
+	return v
+}
+
+// attachmentPath returns the site path of a file linked or embedded by a
+// wikilink and marks it for copying to the output directory.
+func attachmentPath(cache *BuildCache, file string) string {
+	publishFile(cache, file)
+	return sitePath((&url.URL{Path: "/" + file}).EscapedPath())
+}
+
+// publishFile marks file, a slash separated path from the site root, for
+// copying to the same path in the output directory, and reports whether
+// it will be there. Files under images/ and js/ are copied with the static
+// assets. Files outside the site, in hidden directories or in the output
+// directory are never published.
+func publishFile(cache *BuildCache, file string) bool {
+	file = path.Clean(file)
+	if !inSite(file) || !fileExists(file) {
+		return false
+	}
+	if strings.HasPrefix(file, "images/") || strings.HasPrefix(file, "js/") {
+		return true
+	}
+	out := filepath.ToSlash(filepath.Clean(cache.CurrentBuildOutputDir))
+	if file == out || strings.HasPrefix(file, out+"/") || strings.HasPrefix(file, ".") || strings.Contains(file, "/.") {
+		return false
+	}
+	v := siteVault(cache)
+	v.mu.Lock()
+	v.copied[file] = true
+	v.mu.Unlock()
+	return true
+}
+
+// copyAttachments copies the files pages link to or show, outside images/
+// and js/, into the output directory at the same path: wikilink
+// attachments and images kept next to their pages. Files under images/
+// and js/ are copied with the static assets already.
+func copyAttachments(cache *BuildCache, outputDirRoot string) error {
+	v := siteVault(cache)
+	var written []string
+	for _, file := range sortedKeys(v.copied) {
+		if strings.HasPrefix(file, "images/") || strings.HasPrefix(file, "js/") {
+			continue
+		}
+		dest := filepath.Join(outputDirRoot, filepath.FromSlash(file))
+		if rel, err := filepath.Rel(outputDirRoot, dest); err != nil || !inSite(filepath.ToSlash(rel)) {
+			return fmt.Errorf("attachment %s is outside the output directory", file)
+		}
+		if err := copyFile(filepath.FromSlash(file), dest); err != nil {
+			return fmt.Errorf("error copying %s: %w", file, err)
+		}
+		written = append(written, file)
+		fmt.Printf("Copied: %s\n", dest)
+	}
+	cache.NextManifest.recordGenerated("#attachments", written)
+	return nil
+}
+
+// pageSetHash hashes the path and title of every page. Pages with
+// wikilinks depend on it, since a new or renamed page can change what a
+// wikilink points to.
+func pageSetHash(cache *BuildCache) string {
+	return siteVault(cache).hash
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// checkOptions are the command line settings of krems --check.
+type checkOptions struct {
+	Dir      string           // built site to check
+	BasePath string           // website.basePath the site was built with
+	External *externalChecker // also request external links; nil skips them
+}
+
+// handleCheck => krems --check
+// It validates the links of an already built site and exits non-zero when
+// any are broken, so it can gate a deploy.
+func handleCheck(opts checkOptions) {
+	problems, err := checkSite(opts)
+	if err != nil {
+		fmt.Printf("Check failed: %v\n", err)
+		os.Exit(1)
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
+
+var reCheckRefresh = regexp.MustCompile(`(?i)http-equiv\s*=\s*["']?refresh`)
+
+// checkedPage is one HTML file of the built site.
+type checkedPage struct {
+	file     string // relative to the site directory, slash separated
+	links    []checkedLink
+	ids      map[string]bool
+	redirect bool // a stub that only forwards to another URL
+}
+
+// checkedLink is one href, src or form action attribute.
+type checkedLink struct {
+	url  string
+	line int
+}
+
+// checkSite reads every HTML file in opts.Dir, reports each href, src and
+// form action that points to a missing file or #fragment and each page no
+// other page links to, and returns the number of broken links.
+func checkSite(opts checkOptions) (int, error) {
+	pages := make(map[string]*checkedPage)
+	err := filepath.WalkDir(opts.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".html" {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel := relOutput(opts.Dir, p)
+		pages[rel] = parseCheckedPage(rel, data)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(pages) == 0 {
+		return 0, fmt.Errorf("no HTML files in %s; build the site first", opts.Dir)
+	}
+
+	var problems []*sourceError
+	external := make(map[string][]linkLocation)
+	linkedFrom := make(map[string]bool)
+	links := 0
+	for _, file := range sortedPageFiles(pages) {
+		page := pages[file]
+		for _, link := range page.links {
+			links++
+			target, fragment, problem := resolveCheckedURL(opts, page.file, link.url)
+			if isExternalURL(link.url) {
+				external[link.url] = append(external[link.url], linkLocation{
+					File: filepath.ToSlash(filepath.Join(opts.Dir, page.file)),
+					Line: link.line,
+				})
+			}
+			if problem == "" && target != "" {
+				problem = checkTarget(opts, pages, target, fragment)
+				if target != page.file {
+					linkedFrom[target] = true
+				}
+			}
+			if problem != "" {
+				problems = append(problems, &sourceError{
+					File: filepath.ToSlash(filepath.Join(opts.Dir, page.file)),
+					Line: link.line,
+					Err:  fmt.Errorf("%s: %s", link.url, problem),
+				})
+			}
+		}
+	}
+
+	for _, p := range problems {
+		fmt.Printf("Broken: %s\n", p)
+	}
+	orphans := 0
+	for _, file := range sortedPageFiles(pages) {
+		if file == "index.html" || file == "404.html" || linkedFrom[file] || pages[file].redirect {
+			continue
+		}
+		orphans++
+		fmt.Printf("Orphan: %s is not linked from any page\n", filepath.ToSlash(filepath.Join(opts.Dir, file)))
+	}
+	broken := len(problems)
+	if opts.External != nil {
+		broken += checkExternalLinks(opts.External, external)
+	}
+	fmt.Printf("Checked %d page(s) and %d link(s): %d broken, %d orphan page(s).\n", len(pages), links, broken, orphans)
+	return broken, nil
+}
+
+// isExternalURL reports whether raw is an http(s) URL on another site.
+func isExternalURL(raw string) bool {
+	lc := strings.ToLower(raw)
+	return strings.HasPrefix(lc, "http://") || strings.HasPrefix(lc, "https://")
+}
+
+// parseCheckedPage collects the links and the ids of an HTML file.
+func parseCheckedPage(file string, data []byte) *checkedPage {
+	page := &checkedPage{file: file, ids: make(map[string]bool), redirect: reCheckRefresh.Match(data)}
+	line, counted := 1, 0
+	for _, a := range htmlAttrs(string(data)) {
+		switch a.Name {
+		case "href", "src", "action":
+			line += bytes.Count(data[counted:a.Start], []byte("\n"))
+			counted = a.Start
+			page.links = append(page.links, checkedLink{url: a.Value, line: line})
+		case "id", "name":
+			page.ids[a.Value] = true
+		}
+	}
+	return page
+}
+
+// resolveCheckedURL maps a link found in file to the file it points to in
+// the site directory. External links, mailto: and the like return an empty
+// target; links that can't be served from the site return a problem.
+func resolveCheckedURL(opts checkOptions, file, raw string) (target, fragment, problem string) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", "invalid URL"
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return "", "", ""
+	}
+	fragment = u.Fragment
+	if u.Path == "" {
+		return file, fragment, "" // "#section" or "?q=" on the same page
+	}
+
+	var p string
+	if strings.HasPrefix(u.Path, "/") {
+		basePath := strings.TrimSuffix(opts.BasePath, "/")
+		if basePath != "" && u.Path != basePath && !strings.HasPrefix(u.Path, basePath+"/") {
+			return "", "", fmt.Sprintf("outside basePath %s", opts.BasePath)
+		}
+		p = strings.TrimPrefix(u.Path, basePath)
+	} else {
+		p = path.Join("/", path.Dir(file), u.Path)
+		if strings.HasSuffix(u.Path, "/") {
+			p += "/"
+		}
+	}
+
+	p = strings.TrimPrefix(p, "/")
+	if p == "" || strings.HasSuffix(p, "/") {
+		return p + "index.html", fragment, ""
+	}
+	if info, err := os.Stat(filepath.Join(opts.Dir, filepath.FromSlash(p))); err == nil && info.IsDir() {
+		return p + "/index.html", fragment, ""
+	}
+	return p, fragment, ""
+}
+
+// checkTarget reports a problem when target is missing from the site or
+// does not contain the #fragment.
+func checkTarget(opts checkOptions, pages map[string]*checkedPage, target, fragment string) string {
+	page, isPage := pages[target]
+	if !isPage {
+		if _, err := os.Stat(filepath.Join(opts.Dir, filepath.FromSlash(target))); err != nil {
+			return "no such file"
+		}
+		return ""
+	}
+	if fragment != "" && !page.ids[fragment] {
+		return fmt.Sprintf("no element with id %q", fragment)
+	}
+	return ""
+}
+
+func sortedPageFiles(pages map[string]*checkedPage) []string {
+	files := make([]string, 0, len(pages))
+	for f := range pages {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
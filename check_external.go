@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the check.external section of config.yaml.
+const (
+	defaultExternalTimeout   = 10 * time.Second
+	defaultExternalCacheTTL  = 24 * time.Hour
+	defaultExternalPerHost   = 2
+	defaultExternalCacheFile = ".krems-linkcache.json"
+	externalMaxConcurrency   = 16
+)
+
+// externalChecker requests external URLs, at most perHost at a time for
+// any one host, and remembers working URLs in a cache file for ttl.
+type externalChecker struct {
+	client    *http.Client
+	perHost   int
+	ttl       time.Duration
+	cacheFile string // empty disables the cache
+	ignore    []*regexp.Regexp
+	allow     []*regexp.Regexp
+}
+
+// externalResult is the outcome of checking one URL.
+type externalResult struct {
+	Status  int       `json:"status,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+func (r externalResult) ok() bool {
+	return r.Error == "" && r.Status < 400
+}
+
+// limited reports a result that says nothing about the link itself: the
+// server asked us to slow down.
+func (r externalResult) limited() bool {
+	return r.Status == http.StatusTooManyRequests
+}
+
+func (r externalResult) String() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	return fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+}
+
+// newExternalChecker builds a checker from config.yaml settings. Links to
+// siteURL are the site's own pages, already covered by the offline check.
+func newExternalChecker(cfg ExternalCheckConfig, siteURL string) (*externalChecker, error) {
+	c := &externalChecker{
+		perHost:   cfg.PerHost,
+		ttl:       defaultExternalCacheTTL,
+		cacheFile: cfg.CacheFile,
+	}
+	timeout := defaultExternalTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid check.external.timeout: %w", err)
+		}
+		timeout = d
+	}
+	c.client = &http.Client{Timeout: timeout}
+	if cfg.CacheTTL != "" {
+		d, err := time.ParseDuration(cfg.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid check.external.cacheTTL: %w", err)
+		}
+		c.ttl = d
+	}
+	if c.perHost < 1 {
+		c.perHost = defaultExternalPerHost
+	}
+	if c.cacheFile == "" {
+		c.cacheFile = defaultExternalCacheFile
+	}
+	if c.ttl <= 0 {
+		c.cacheFile = ""
+	}
+	ignore := cfg.Ignore
+	if siteURL = strings.TrimSuffix(siteURL, "/"); siteURL != "" {
+		ignore = append([]string{siteURL + "/*"}, ignore...)
+	}
+	c.ignore = urlPatterns(ignore)
+	c.allow = urlPatterns(cfg.Allow)
+	return c, nil
+}
+
+// urlPatterns compiles patterns in which * matches any run of characters;
+// everything else matches literally, from the start of the URL.
+func urlPatterns(patterns []string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*")
+		res = append(res, regexp.MustCompile(expr))
+	}
+	return res
+}
+
+func matchesAny(patterns []*regexp.Regexp, u string) bool {
+	for _, re := range patterns {
+		if re.MatchString(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// wants reports whether u should be requested at all.
+func (c *externalChecker) wants(u string) bool {
+	if matchesAny(c.ignore, u) {
+		return false
+	}
+	return len(c.allow) == 0 || matchesAny(c.allow, u)
+}
+
+// checkAll checks every URL in urls and returns the results by URL.
+// Working URLs found in the cache are not requested again.
+func (c *externalChecker) checkAll(urls []string) map[string]externalResult {
+	cache := c.loadCache()
+	now := time.Now()
+	results := make(map[string]externalResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	hosts := make(map[string]chan struct{})
+	global := make(chan struct{}, externalMaxConcurrency)
+
+	for _, u := range urls {
+		if r, ok := cache[u]; ok && r.ok() && now.Sub(r.Checked) < c.ttl {
+			results[u] = r
+			continue
+		}
+		host := u
+		if parsed, err := url.Parse(u); err == nil {
+			host = parsed.Host
+		}
+		sem, ok := hosts[host]
+		if !ok {
+			sem = make(chan struct{}, c.perHost)
+			hosts[host] = sem
+		}
+		wg.Add(1)
+		go func(u string, sem chan struct{}) {
+			defer wg.Done()
+			sem <- struct{}{}
+			global <- struct{}{}
+			r := c.check(u)
+			<-global
+			<-sem
+			mu.Lock()
+			results[u] = r
+			if r.ok() {
+				cache[u] = r
+			}
+			mu.Unlock()
+		}(u, sem)
+	}
+	wg.Wait()
+
+	c.saveCache(cache)
+	return results
+}
+
+// check requests u with HEAD and falls back to GET, since some servers
+// reject or mishandle HEAD. A host that is rate limiting gets no second
+// request.
+func (c *externalChecker) check(u string) externalResult {
+	r := c.request(http.MethodHead, u)
+	if !r.ok() && !r.limited() {
+		r = c.request(http.MethodGet, u)
+	}
+	return r
+}
+
+func (c *externalChecker) request(method, u string) externalResult {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return externalResult{Error: err.Error(), Checked: time.Now()}
+	}
+	req.Header.Set("User-Agent", "krems-linkcheck")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return externalResult{Error: err.Error(), Checked: time.Now()}
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return externalResult{Status: resp.StatusCode, Checked: time.Now()}
+}
+
+func (c *externalChecker) loadCache() map[string]externalResult {
+	cache := make(map[string]externalResult)
+	if c.cacheFile == "" {
+		return cache
+	}
+	data, err := os.ReadFile(c.cacheFile)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		fmt.Printf("Warning: ignoring unreadable link cache %s: %v\n", c.cacheFile, err)
+		return make(map[string]externalResult)
+	}
+	return cache
+}
+
+// saveCache writes the cache, dropping entries older than the TTL.
+func (c *externalChecker) saveCache(cache map[string]externalResult) {
+	if c.cacheFile == "" {
+		return
+	}
+	now := time.Now()
+	for u, r := range cache {
+		if now.Sub(r.Checked) >= c.ttl {
+			delete(cache, u)
+		}
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = os.WriteFile(c.cacheFile, data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to write link cache %s: %v\n", c.cacheFile, err)
+	}
+}
+
+// linkLocation is where a link appears in the built site.
+type linkLocation struct {
+	File string
+	Line int
+}
+
+// checkExternalLinks checks the external links found in the site and
+// prints the broken ones at every place they occur. It returns the number
+// of broken links.
+func checkExternalLinks(c *externalChecker, found map[string][]linkLocation) int {
+	var urls []string
+	for u := range found {
+		if c.wants(u) {
+			urls = append(urls, u)
+		}
+	}
+	sort.Strings(urls)
+	fmt.Printf("Checking %d external URL(s)...\n", len(urls))
+
+	results := c.checkAll(urls)
+	broken := 0
+	for _, u := range urls {
+		r := results[u]
+		if r.ok() {
+			continue
+		}
+		for _, loc := range found[u] {
+			if r.limited() {
+				fmt.Printf("Warning: %s:%d: %s: rate limited, not verified\n", loc.File, loc.Line, u)
+				continue
+			}
+			broken++
+			fmt.Printf("Broken: %s:%d: %s: %s\n", loc.File, loc.Line, u, r)
+		}
+	}
+	return broken
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testChecker returns a checker without a link cache, unless cacheFile is
+// set.
+func testChecker(t *testing.T, cfg ExternalCheckConfig) *externalChecker {
+	t.Helper()
+	if cfg.CacheFile == "" {
+		cfg.CacheTTL = "0s"
+	}
+	c, err := newExternalChecker(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestExternalCheckerStatus(t *testing.T) {
+	var gets sync.Map // path => number of GET requests
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			n, _ := gets.LoadOrStore(r.URL.Path, new(int32))
+			atomic.AddInt32(n.(*int32), 1)
+		}
+		switch r.URL.Path {
+		case "/ok":
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	results := testChecker(t, ExternalCheckConfig{}).checkAll([]string{
+		srv.URL + "/ok", srv.URL + "/missing", srv.URL + "/no-head", srv.URL + "/limited",
+	})
+	tests := []struct {
+		path   string
+		status int
+		ok     bool
+		gets   int32
+	}{
+		{"/ok", http.StatusOK, true, 0},
+		{"/missing", http.StatusNotFound, false, 1},
+		{"/no-head", http.StatusOK, true, 1},
+		{"/limited", http.StatusTooManyRequests, false, 0},
+	}
+	for _, tt := range tests {
+		r := results[srv.URL+tt.path]
+		if r.Status != tt.status || r.ok() != tt.ok {
+			t.Errorf("%s: got %v (ok %v), want %d (ok %v)", tt.path, r, r.ok(), tt.status, tt.ok)
+		}
+		var got int32
+		if n, ok := gets.Load(tt.path); ok {
+			got = atomic.LoadInt32(n.(*int32))
+		}
+		if got != tt.gets {
+			t.Errorf("%s: %d GET request(s), want %d", tt.path, got, tt.gets)
+		}
+	}
+}
+
+func TestExternalCheckerPerHost(t *testing.T) {
+	var inFlight, most int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer srv.Close()
+
+	var urls []string
+	for _, p := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		urls = append(urls, srv.URL+"/"+p)
+	}
+	results := testChecker(t, ExternalCheckConfig{PerHost: 2}).checkAll(urls)
+	for _, u := range urls {
+		if !results[u].ok() {
+			t.Errorf("%s: %v", u, results[u])
+		}
+	}
+	if most > 2 {
+		t.Errorf("%d concurrent requests to one host, want at most 2", most)
+	}
+}
+
+func TestExternalCheckerCache(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer srv.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "links.json")
+	c := testChecker(t, ExternalCheckConfig{CacheFile: cacheFile, CacheTTL: "1h"})
+	u := srv.URL + "/page"
+
+	c.checkAll([]string{u})
+	c.checkAll([]string{u})
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("%d request(s) with a fresh cache entry, want 1", n)
+	}
+
+	// Age the entry past the TTL; the next check requests the URL again.
+	expired := map[string]externalResult{u: {Status: http.StatusOK, Checked: time.Now().Add(-2 * time.Hour)}}
+	data, err := json.Marshal(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cacheFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.checkAll([]string{u})
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("%d request(s) after the cache entry expired, want 2", n)
+	}
+}
@@ -3,9 +3,13 @@ module github.com/mreider/krems
 go 1.23.5
 
 require (
+	github.com/alecthomas/chroma/v2 v2.24.1
 	github.com/gomarkdown/markdown v0.0.0-20250202022148-4f606c78d442
 	github.com/gosimple/slug v1.15.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/gosimple/unidecode v1.0.1 // indirect
+require (
+	github.com/dlclark/regexp2 v1.12.0 // indirect
+	github.com/gosimple/unidecode v1.0.1 // indirect
+)
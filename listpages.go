@@ -4,109 +4,38 @@ import (
 	"fmt"
 	"html/template"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
 	"github.com/gosimple/slug"
 )
 
+// listStyles are the values of listStyle front matter. Each has a partial
+// "list-<style>" that renders the listed pages; "list" is the default.
+var listStyles = []string{"list", "cards", "compact"}
+
 // listPagesInDirectory => for type:list pages at any level
-func listPagesInDirectory(relPath string) template.HTML {
+// It renders the "list" style in Go for layouts written before the list
+// partials; the default layouts use listedPages instead. It takes the list
+// page itself (.Page), or its RelPath as older layouts pass it.
+func listPagesInDirectory(page any) template.HTML {
 	if globalBuildCache == nil {
 		return ""
 	}
 
-	// 1. Find the listing page
 	var listingPage *PageData
-	for _, p := range globalBuildCache.Pages {
-		if p.RelPath == relPath {
-			listingPage = p
-			break
-		}
-	}
-	if listingPage == nil {
-		// try to find index.md in the directory
-		dir := filepath.Dir(relPath)
-		if !strings.HasSuffix(dir, "index.md") {
-			dir = filepath.Join(dir, "index.md")
-		}
-		for _, p := range globalBuildCache.Pages {
-			if p.RelPath == dir {
-				listingPage = p
-				break
-			}
-		}
+	switch p := page.(type) {
+	case *PageData:
+		listingPage = p
+	case string:
+		listingPage = findListingPage(globalBuildCache, p)
 	}
 	if listingPage == nil {
 		return ""
 	}
+	siblings := listingPage.listedPages(globalBuildCache)
 
-	// 2. Determine the directory for that page
-	dir := filepath.Dir(listingPage.RelPath)
-	if dir == "." {
-		dir = ""
-	}
-	
-	// 3. Gather pages with a valid date, skipping index pages
-	var siblings []*PageData
-	for _, p := range globalBuildCache.Pages {
-		// Skip index pages and pages without dates
-		if p.IsIndex || p.FrontMatter.ParsedDate.IsZero() {
-			continue
-		}
-		
-		include := true
-		
-		// For author pages, we need to include all posts by the author regardless of directory
-		if len(listingPage.FrontMatter.AuthorFilter) > 0 {
-			include = false // Default to exclude
-			for _, filterAuthor := range listingPage.FrontMatter.AuthorFilter {
-				// Case-insensitive comparison for author names
-				if strings.EqualFold(strings.TrimSpace(p.FrontMatter.Author), strings.TrimSpace(filterAuthor)) {
-					include = true
-					break
-				}
-			}
-		} else if len(listingPage.FrontMatter.TagFilter) > 0 {
-			// Handle tag filtering
-			include = false // Default to exclude
-			for _, pageTag := range p.FrontMatter.Tags {
-				for _, filterTag := range listingPage.FrontMatter.TagFilter {
-					// Case-insensitive comparison for tags
-					if strings.EqualFold(strings.TrimSpace(pageTag), strings.TrimSpace(filterTag)) {
-						include = true
-						break
-					}
-				}
-				if include {
-					break
-				}
-			}
-		} else {
-			// For regular list pages, only include pages from the same directory
-			pageDir := filepath.Dir(p.RelPath)
-			if pageDir == "." {
-				pageDir = ""
-			}
-			
-			// Check if page is in the same directory as the listing page
-			if pageDir != dir {
-				include = false
-			}
-		}
-
-		if include {
-			siblings = append(siblings, p)
-		}
-	}
-	
-	// 4. Sort descending by date
-	sort.Slice(siblings, func(i, j int) bool {
-		return siblings[i].FrontMatter.ParsedDate.After(siblings[j].FrontMatter.ParsedDate)
-	})
-
-	// 5. Group by year=>month, then build HTML
+	// Group by year=>month, then build HTML
 	groups := groupByYearThenMonth(siblings)
 
 	var sb strings.Builder
@@ -150,47 +79,93 @@ func listPagesInDirectory(relPath string) template.HTML {
 	return template.HTML(sb.String())
 }
 
-// groupByYearThenMonth lumps pages by Year => Month => Pages
-func groupByYearThenMonth(pages []*PageData) []yearGroup {
-	yMap := make(map[int]map[time.Month][]*PageData)
-	for _, p := range pages {
-		y := p.FrontMatter.ParsedDate.Year()
-		m := p.FrontMatter.ParsedDate.Month()
-		if _, ok := yMap[y]; !ok {
-			yMap[y] = make(map[time.Month][]*PageData)
+// findListingPage returns the list page for relPath, falling back to the
+// index.md of the same directory.
+func findListingPage(cache *BuildCache, relPath string) *PageData {
+	var listingPage *PageData
+	for _, p := range allPages(cache) {
+		if p.RelPath == relPath {
+			listingPage = p
+			break
 		}
-		yMap[y][m] = append(yMap[y][m], p)
 	}
+	if listingPage == nil {
+		// try to find index.md in the directory
+		dir := filepath.Dir(relPath)
+		if !strings.HasSuffix(dir, "index.md") {
+			dir = filepath.Join(dir, "index.md")
+		}
+		for _, p := range cache.Pages {
+			if p.RelPath == dir {
+				listingPage = p
+				break
+			}
+		}
+	}
+	return listingPage
+}
 
-	// sort years descending
-	var years []int
-	for year := range yMap {
-		years = append(years, year)
+// listedPages returns the pages shown on list page p: its share when the
+// list is paginated, else all of them.
+func (p *PageData) listedPages(cache *BuildCache) []*PageData {
+	if p.Pager != nil {
+		return p.Pager.Pages
 	}
-	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+	return collectListedPages(cache, p)
+}
 
-	var result []yearGroup
-	for _, yr := range years {
-		monthMap := yMap[yr]
-		var months []time.Month
-		for mm := range monthMap {
-			months = append(months, mm)
+// collectListedPages returns the dated pages listed by listingPage, across
+// all its pages when it is paginated: filtered by listIncludes, sorted by
+// sortListedPages and cut to its limit.
+func collectListedPages(cache *BuildCache, listingPage *PageData) []*PageData {
+	if listingPage.Pager != nil {
+		listingPage = listingPage.Pager.First()
+	}
+
+	var listed []*PageData
+	for _, p := range cache.Pages {
+		// Skip index pages and pages without dates
+		if p.IsIndex || p.FrontMatter.ParsedDate.IsZero() {
+			continue
 		}
-		sort.Slice(months, func(i, j int) bool {
-			return months[i] > months[j]
-		})
+		if listIncludes(listingPage, p) {
+			listed = append(listed, p)
+		}
+	}
 
-		var mgs []monthGroup
-		for _, mm := range months {
-			mgs = append(mgs, monthGroup{
-				Month: mm,
-				Pages: monthMap[mm],
-			})
+	fm := listingPage.FrontMatter
+	sortListedPages(listed, fm.SortBy, fm.SortOrder)
+	if fm.Limit > 0 && len(listed) > fm.Limit {
+		listed = listed[:fm.Limit]
+	}
+	return listed
+}
+
+// groupByYearThenMonth lumps pages by Year => Month => Pages. pages are
+// already sorted by sortListedPages, so years and months keep the order in
+// which they first appear: newest first by default, oldest first with
+// sortOrder: asc, and in title or weight order otherwise.
+func groupByYearThenMonth(pages []*PageData) []yearGroup {
+	var result []yearGroup
+	yearIndex := make(map[int]int)
+	monthIndex := make(map[int]map[time.Month]int)
+	for _, p := range pages {
+		y := p.FrontMatter.ParsedDate.Year()
+		m := p.FrontMatter.ParsedDate.Month()
+		yi, ok := yearIndex[y]
+		if !ok {
+			yi = len(result)
+			yearIndex[y] = yi
+			monthIndex[y] = make(map[time.Month]int)
+			result = append(result, yearGroup{Year: y})
+		}
+		mi, ok := monthIndex[y][m]
+		if !ok {
+			mi = len(result[yi].Months)
+			monthIndex[y][m] = mi
+			result[yi].Months = append(result[yi].Months, monthGroup{Month: m})
 		}
-		result = append(result, yearGroup{
-			Year:   yr,
-			Months: mgs,
-		})
+		result[yi].Months[mi].Pages = append(result[yi].Months[mi].Pages, p)
 	}
 	return result
 }
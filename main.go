@@ -13,9 +13,21 @@ const outputDirName = ".tmp" // Changed from "tmp"
 func printUsage() {
 	fmt.Println("Usage: krems <command> [options]")
 	fmt.Println("\nAvailable commands:")
-	fmt.Println("  --build          Builds the static site into the ./.tmp directory.")
-	fmt.Println("  --run [options]  Builds and serves the site locally from ./.tmp.")
+	fmt.Println("  --build [options] Builds the static site into the ./.tmp directory.")
+	fmt.Println("    --jobs <number>  Pages rendered in parallel (default: number of CPUs).")
+	fmt.Println("    --drafts         Include pages marked draft: true.")
+	fmt.Println("    --future         Include pages whose publishDate has not come yet.")
+	fmt.Println("    --expired        Include pages whose expiryDate has passed.")
+	fmt.Println("    --strict         Fail on broken links, missing images and menu paths.")
+	fmt.Println("  --run [options]  Builds and serves the site locally from ./.tmp, rebuilding and")
+	fmt.Println("                   reloading open pages whenever a file changes.")
 	fmt.Println("    --port <number>  Port to run the local server on (default: 8080).")
+	fmt.Println("    --jobs <number>  Pages rendered in parallel (default: number of CPUs).")
+	fmt.Println("    --drafts, --future, --expired")
+	fmt.Println("                     Show unpublished pages with a banner (default: true; use =false to hide).")
+	fmt.Println("  --check [options] Checks the links of a built site and exits non-zero if any are broken.")
+	fmt.Println("    --dir <path>     Built site to check (default: ./.tmp).")
+	fmt.Println("    --external       Also request external links (see check.external in config.yaml).")
 	fmt.Println("  --clean          Removes the ./.tmp build directory.")
 	fmt.Println("  --version        Displays the Krems version.")
 }
@@ -41,11 +53,38 @@ func main() {
 
 	switch os.Args[1] {
 	case "--build":
-		handleBuild(false, outputDirName) // Use constant for output directory
+		buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
+		jobsFlag := buildCmd.Int("jobs", defaultJobs(), "Number of pages rendered in parallel")
+		draftsFlag := buildCmd.Bool("drafts", false, "Include draft pages")
+		futureFlag := buildCmd.Bool("future", false, "Include pages with a publishDate in the future")
+		expiredFlag := buildCmd.Bool("expired", false, "Include pages whose expiryDate has passed")
+		strictFlag := buildCmd.Bool("strict", false, "Fail on broken links, missing images and menu paths")
+		if err := buildCmd.Parse(os.Args[2:]); err != nil {
+			fmt.Printf("Error parsing --build flags: %v\n", err)
+			buildCmd.Usage()
+			os.Exit(1)
+		}
+		if *jobsFlag < 1 {
+			fmt.Printf("Error: Invalid jobs count '%d'. Must be at least 1.\n", *jobsFlag)
+			buildCmd.Usage()
+			os.Exit(1)
+		}
+		handleBuild(outputDirName, buildOptions{ // Use constant for output directory
+			Jobs:    *jobsFlag,
+			Drafts:  *draftsFlag,
+			Future:  *futureFlag,
+			Expired: *expiredFlag,
+			Strict:  *strictFlag,
+		})
 	case "--run":
 		runCmd := flag.NewFlagSet("run", flag.ExitOnError)
 		portFlag := runCmd.String("port", defaultPort, "Port to run the local server on")
-		
+		jobsFlag := runCmd.Int("jobs", defaultJobs(), "Number of pages rendered in parallel")
+		// Previewing shows unpublished pages by default; each can be switched off.
+		draftsFlag := runCmd.Bool("drafts", true, "Include draft pages")
+		futureFlag := runCmd.Bool("future", true, "Include pages with a publishDate in the future")
+		expiredFlag := runCmd.Bool("expired", true, "Include pages whose expiryDate has passed")
+
 		// Parse flags specifically for the "run" command
 		// os.Args[0] is program name, os.Args[1] is "--run"
 		// so flags for "run" start from os.Args[2]
@@ -62,8 +101,42 @@ func main() {
 			runCmd.Usage()
 			os.Exit(1)
 		}
-		// handleRun signature will need to be updated to accept port string
-		handleRun(*portFlag) 
+		if *jobsFlag < 1 {
+			fmt.Printf("Error: Invalid jobs count '%d'. Must be at least 1.\n", *jobsFlag)
+			runCmd.Usage()
+			os.Exit(1)
+		}
+		handleRun(*portFlag, buildOptions{
+			Jobs:    *jobsFlag,
+			Drafts:  *draftsFlag,
+			Future:  *futureFlag,
+			Expired: *expiredFlag,
+		})
+	case "--check":
+		checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+		dirFlag := checkCmd.String("dir", outputDirName, "Built site to check")
+		externalFlag := checkCmd.Bool("external", false, "Also request external links")
+		if err := checkCmd.Parse(os.Args[2:]); err != nil {
+			fmt.Printf("Error parsing --check flags: %v\n", err)
+			checkCmd.Usage()
+			os.Exit(1)
+		}
+		// Links carry the basePath the site was built with (krems --build).
+		cfg, err := readConfig("config.yaml")
+		if err != nil {
+			fmt.Printf("Warning: could not read config.yaml, assuming no basePath: %v\n", err)
+			cfg = &Config{}
+		}
+		opts := checkOptions{Dir: *dirFlag, BasePath: cfg.Website.BasePath}
+		if *externalFlag {
+			checker, err := newExternalChecker(cfg.Check.External, cfg.Website.URL)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.External = checker
+		}
+		handleCheck(opts)
 	case "--clean":
 		handleClean() // To be implemented
 	case "--version":
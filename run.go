@@ -7,12 +7,15 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 )
 
 // handleRun builds the site into the ./.tmp directory,
 // starts a local HTTP server to serve it, and cleans up the directory on exit.
-func handleRun(port string) { // Accept port as a parameter
+// While running, the project is watched for changes: every change triggers a
+// rebuild and open browser tabs are told to reload.
+func handleRun(port string, opts buildOptions) { // Accept port as a parameter
 	// Use the constant outputDirName = ".tmp"
 	// This directory is relative to where krems is run (project root)
 	
@@ -46,19 +49,36 @@ func handleRun(port string) { // Accept port as a parameter
 	}()
 
 	fmt.Println("Building site for local preview...")
-	handleBuild(true, outputDirName) // true for isDevMode, outputDirName for output
+	opts.DevMode = true
+	handleBuild(outputDirName, opts)
 	fmt.Println("Build complete.")
 
+	broker := newReloadBroker()
+	go watchSite(siteWatchRoots, func(changed []string) {
+		fmt.Printf("\nDetected changes in %d file(s): %s\n", len(changed), strings.Join(changed, ", "))
+		if err := buildSite(outputDirName, opts); err != nil {
+			// Keep serving the previous output; the next save will retry.
+			fmt.Printf("Rebuild failed: %v\n", err)
+			return
+		}
+		fmt.Println("Rebuild complete, reloading browsers.")
+		broker.reload()
+	})
+
 	// Use the port parameter
 	fs := http.FileServer(http.Dir(outputDirName))
 
-	http.Handle("/", &loggingFileHandler{
-		root:    outputDirName, // Use the .tmp directory
-		handler: fs,
+	mux := http.NewServeMux()
+	mux.Handle(liveReloadPath, broker)
+	mux.Handle("/", &loggingFileHandler{
+		root:       outputDirName, // Use the .tmp directory
+		handler:    fs,
+		liveReload: true,
 	})
 
 	fmt.Printf("Serving '%s' on http://localhost:%s ... (Press Ctrl+C to stop)\n", outputDirName, port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	fmt.Println("Watching for changes; pages reload automatically.")
+	log.Fatal(http.ListenAndServe(":"+port, mux))
 }
 
 // loggingFileHandler intercepts requests to:
@@ -67,9 +87,11 @@ func handleRun(port string) { // Accept port as a parameter
 //   - Otherwise delegate to the FileServer
 //
 // It also logs each request with the final HTTP status code.
+// With liveReload set, HTML pages get the live reload script injected.
 type loggingFileHandler struct {
-	root    string // e.g. ".tmp"
-	handler http.Handler
+	root       string // e.g. ".tmp"
+	handler    http.Handler
+	liveReload bool
 }
 
 func (l *loggingFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -82,6 +104,7 @@ func (l *loggingFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	htmlPath := ""
 	if info.IsDir() {
 		indexPath := filepath.Join(fullPath, "index.html")
 		fi, err2 := os.Stat(indexPath)
@@ -89,6 +112,21 @@ func (l *loggingFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			l.notFound(lrw, r)
 			return
 		}
+		// Directories without a trailing slash are left to the FileServer,
+		// which redirects them so relative links keep working.
+		if strings.HasSuffix(r.URL.Path, "/") {
+			htmlPath = indexPath
+		}
+	} else if strings.HasSuffix(fullPath, ".html") && filepath.Base(fullPath) != "index.html" {
+		htmlPath = fullPath
+	}
+
+	if l.liveReload && htmlPath != "" {
+		if err := serveHTMLWithReload(lrw, htmlPath, http.StatusOK); err != nil {
+			log.Printf("Error serving %s: %v", htmlPath, err)
+		}
+		log.Printf("%s %s -> %d\n", r.Method, r.URL.Path, lrw.statusCode)
+		return
 	}
 
 	l.handler.ServeHTTP(lrw, r)
@@ -96,9 +134,16 @@ func (l *loggingFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (l *loggingFileHandler) notFound(lrw *loggingResponseWriter, r *http.Request) {
-	lrw.WriteHeader(http.StatusNotFound)
-
 	notFoundPath := filepath.Join(l.root, "404.html")
+	if _, err := os.Stat(notFoundPath); err == nil && l.liveReload {
+		if err := serveHTMLWithReload(lrw, notFoundPath, http.StatusNotFound); err != nil {
+			log.Printf("Error serving %s: %v", notFoundPath, err)
+		}
+		log.Printf("%s %s -> %d\n", r.Method, r.URL.Path, http.StatusNotFound)
+		return
+	}
+
+	lrw.WriteHeader(http.StatusNotFound)
 	if _, err := os.Stat(notFoundPath); err == nil {
 		http.ServeFile(lrw, r, notFoundPath)
 	} else {
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// liveReloadPath is the Server-Sent Events endpoint browsers subscribe to.
+// It is only served by krems --run and never written to the build output.
+const liveReloadPath = "/__krems/livereload"
+
+// liveReloadScript is injected into every HTML page served by krems --run.
+const liveReloadScript = `<script>
+(function () {
+    var source = new EventSource("` + liveReloadPath + `");
+    source.addEventListener("reload", function () { window.location.reload(); });
+})();
+</script>
+`
+
+// reloadBroker fans out reload events to every connected browser tab.
+type reloadBroker struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newReloadBroker() *reloadBroker {
+	return &reloadBroker{clients: make(map[chan struct{}]struct{})}
+}
+
+// reload notifies all connected clients. Clients that already have a
+// pending reload are skipped, so a burst of rebuilds results in one reload.
+func (b *reloadBroker) reload() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *reloadBroker) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.clients[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *reloadBroker) unsubscribe(ch chan struct{}) {
+	b.mu.Lock()
+	delete(b.clients, ch)
+	b.mu.Unlock()
+}
+
+func (b *reloadBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	// Periodic comments keep proxies and browsers from timing out the stream.
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-ch:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// serveHTMLWithReload writes the HTML file at path with the live reload
+// script inserted before </body> (or appended if there is none).
+func serveHTMLWithReload(w http.ResponseWriter, path string, status int) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if i := bytes.LastIndex(content, []byte("</body>")); i != -1 {
+		content = append(content[:i:i], append([]byte(liveReloadScript), content[i:]...)...)
+	} else {
+		content = append(content, []byte(liveReloadScript)...)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, err = w.Write(content)
+	return err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often the project tree is polled for changes.
+const watchInterval = 500 * time.Millisecond
+
+// fileStamp is what we compare between two scans to decide a file changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchSite polls the given roots and calls onChange with the changed paths
+// whenever a file is added, modified or removed. roots is called before every
+// scan so that a config.yaml edit that points at a new CSS/JS directory is
+// picked up without restarting. It never returns.
+func watchSite(roots func() []string, onChange func(changed []string)) {
+	previous := scanWatchRoots(roots())
+	for {
+		time.Sleep(watchInterval)
+		current := scanWatchRoots(roots())
+
+		var changed []string
+		for p, stamp := range current {
+			old, ok := previous[p]
+			if !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+				changed = append(changed, p)
+			}
+		}
+		for p := range previous {
+			if _, ok := current[p]; !ok {
+				changed = append(changed, p)
+			}
+		}
+		previous = current
+
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			onChange(changed)
+		}
+	}
+}
+
+// scanWatchRoots walks every root and records a stamp for each file.
+// Hidden directories (.tmp, .git, .obsidian, ...) and editor swap files are
+// skipped so the build output and tool state never trigger a rebuild.
+func scanWatchRoots(roots []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		err := filepath.Walk(root, func(p string, info fs.FileInfo, err error) error {
+			if err != nil {
+				// A file vanishing mid-walk is normal while an editor saves.
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			name := info.Name()
+			if info.IsDir() {
+				if p != root && strings.HasPrefix(name, ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".swp") {
+				return nil
+			}
+			stamps[filepath.Clean(p)] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to scan %s for changes: %v", root, err)
+		}
+	}
+	return stamps
+}
+
+// siteWatchRoots returns the project root plus any alternative CSS/JS
+// directories and favicon configured in config.yaml, which may live
+// outside the project root.
+func siteWatchRoots() []string {
+	roots := []string{"."}
+	cfg, err := readConfig("config.yaml")
+	if err != nil {
+		return roots
+	}
+	for _, extra := range []string{
+		cfg.Website.AlternativeCSSDir,
+		cfg.Website.AlternativeJSDir,
+		cfg.Website.AlternativeFavicon,
+	} {
+		if extra != "" {
+			roots = append(roots, extra)
+		}
+	}
+	return roots
+}